@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// --- Variables for apply flags ---
+var (
+	applyChanges bool // Flag to write file blocks from the response to disk
+	onlyClean    bool // Flag to skip files with uncommitted git changes when applying
+	forceApply   bool // Flag to apply even to files --only-clean would skip
+)
+
+// applyInstructions is appended to the user prompt with --apply so the model
+// labels every file it changes.
+const applyInstructions = `
+
+Put the complete new content of every file you create or change in its own fenced code block whose info string is the language followed by the file path relative to the project root, for example ` + "```" + `go lib/a.go`
+
+// fileEdit is a whole-file replacement proposed by the model.
+type fileEdit struct {
+	path    string // Path relative to the project root, as given in the block's info string
+	content string
+}
+
+// fileEdits extracts the code blocks that name a target file ("```go lib/a.go").
+func fileEdits(response string) []fileEdit {
+	var edits []fileEdit
+	for _, b := range findCodeBlocks(response) {
+		fields := strings.Fields(b.info)
+		if len(fields) < 2 {
+			continue
+		}
+		edits = append(edits, fileEdit{path: fields[len(fields)-1], content: b.body})
+	}
+	return edits
+}
+
+// applyResponse writes the files in response under root: the JSON edits with
+// --json-schema edits, otherwise the labelled code blocks. The --check command
+// then runs on the result.
+func applyResponse(root, response string) error {
+	var edits []fileEdit
+	if jsonSchemaSpec != "" {
+		var err error
+		if edits, err = parseJSONEdits(response); err != nil {
+			return err
+		}
+	} else {
+		edits = fileEdits(response)
+		if len(edits) == 0 {
+			warnf("apply", "--apply found no code blocks labelled with a file path; nothing written.\n")
+			return nil
+		}
+	}
+	applied, err := applyEdits(root, edits)
+	if err != nil {
+		return err
+	}
+	return checkApplied(root, applied)
+}
+
+// appliedEdit is a file applyEdits wrote.
+type appliedEdit struct {
+	path     string // As given by the model
+	target   string // Absolute path written
+	backedUp bool   // The file existed and was backed up to <target>.vibe.bak
+}
+
+// applyEdits writes each edit under root, returning the files written. Existing
+// files are backed up to <file>.vibe.bak first.
+func applyEdits(root string, edits []fileEdit) ([]appliedEdit, error) {
+	var applied []appliedEdit
+	for _, e := range edits {
+		target := filepath.Join(root, filepath.FromSlash(e.path))
+		if rel, err := filepath.Rel(root, target); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			warnf("apply", "Skipping %s: outside the target directory\n", e.path)
+			continue
+		}
+
+		if onlyClean && !forceApply {
+			dirty, err := hasUncommittedChanges(root, target)
+			if err != nil {
+				return applied, err
+			}
+			if dirty {
+				warnf("apply", "Skipping %s: it has uncommitted changes (use --force to overwrite)\n", e.path)
+				continue
+			}
+		}
+
+		edit := appliedEdit{path: e.path, target: target}
+		if existing, err := os.ReadFile(target); err == nil {
+			if err := os.WriteFile(target+".vibe.bak", existing, 0o644); err != nil {
+				return applied, fmt.Errorf("failed to back up %s: %w", e.path, err)
+			}
+			edit.backedUp = true
+		} else if !os.IsNotExist(err) {
+			return applied, fmt.Errorf("failed to read %s: %w", e.path, err)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return applied, fmt.Errorf("failed to create directory for %s: %w", e.path, err)
+		}
+		if err := os.WriteFile(target, []byte(e.content), 0o644); err != nil {
+			return applied, fmt.Errorf("failed to write %s: %w", e.path, err)
+		}
+		logf("Applied changes to %s\n", e.path)
+		applied = append(applied, edit)
+	}
+	logf("Applied %d of %d file(s).\n", len(applied), len(edits))
+	return applied, nil
+}
+
+// hasUncommittedChanges reports whether git sees local modifications to path.
+// Untracked files, and directories that aren't git repositories, count as clean.
+func hasUncommittedChanges(root, path string) (bool, error) {
+	out, err := runGit(root, "status", "--porcelain", "-z", "--", path)
+	if errors.Is(err, exec.ErrNotFound) {
+		return false, fmt.Errorf("--only-clean needs git: %w", err)
+	}
+	if err != nil {
+		verbosef("Treating %s as clean: %v\n", path, err)
+		return false, nil
+	}
+	for _, entry := range strings.Split(out, "\x00") {
+		if entry != "" && !strings.HasPrefix(entry, "??") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestHasUncommittedChanges(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found on PATH")
+	}
+	root := writeTree(t, map[string]string{"clean.go": "package p\n", "my file.go": "package p\n"})
+	gitInit(t, root)
+	if err := os.WriteFile(filepath.Join(root, "my file.go"), []byte("package p\n\n// edited\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "new.go"), []byte("package p\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"clean.go", false},
+		{"my file.go", true},
+		{"new.go", false}, // Untracked files count as clean
+	}
+	for _, tt := range tests {
+		got, err := hasUncommittedChanges(root, filepath.Join(root, tt.name))
+		if err != nil {
+			t.Fatalf("hasUncommittedChanges(%s): %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("hasUncommittedChanges(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// Outside a repository everything is clean
+	outside := writeTree(t, map[string]string{"a.go": "package p\n"})
+	if got, err := hasUncommittedChanges(outside, filepath.Join(outside, "a.go")); err != nil || got {
+		t.Errorf("hasUncommittedChanges outside a repository = %v, %v; want false, nil", got, err)
+	}
+}
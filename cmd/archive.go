@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// isArchive reports whether path names an archive (.zip, .tar.gz or .tgz)
+// that show and code can read in place of a directory.
+func isArchive(path string) bool {
+	lower := strings.ToLower(path)
+	return strings.HasSuffix(lower, ".zip") || strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz")
+}
+
+// extractArchive unpacks the archive at path into a new temporary directory,
+// so the usual walk and filters apply to it. The returned func removes the
+// directory. Only regular files and directories are extracted; entries that
+// would land outside the directory are rejected.
+func extractArchive(path string) (string, func(), error) {
+	dir, err := os.MkdirTemp("", "vibe-archive-")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create a directory for %s: %w", path, err)
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+
+	if strings.HasSuffix(strings.ToLower(path), ".zip") {
+		err = extractZip(path, dir)
+	} else {
+		err = extractTarGz(path, dir)
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to extract %s: %w", path, err)
+	}
+	verbosef("Extracted %s to %s\n", path, dir)
+	return dir, cleanup, nil
+}
+
+func extractZip(path, dir string) error {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			if _, err := archiveEntryPath(dir, f.Name, true); err != nil {
+				return err
+			}
+			continue
+		}
+		if !f.Mode().IsRegular() {
+			continue // Symlinks and the like could point anywhere
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = writeArchiveEntry(dir, f.Name, rc, f.Modified)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractTarGz(path, dir string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if _, err := archiveEntryPath(dir, hdr.Name, true); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := writeArchiveEntry(dir, hdr.Name, tr, hdr.ModTime); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// archiveEntryPath returns where the entry name goes under dir, creating its
+// parent directories (or, with isDir, the directory itself).
+func archiveEntryPath(dir, name string, isDir bool) (string, error) {
+	dest := filepath.Join(dir, filepath.FromSlash(name))
+	if dest != dir && !strings.HasPrefix(dest, dir+string(filepath.Separator)) {
+		return "", fmt.Errorf("entry %q points outside the archive", name)
+	}
+	parent := filepath.Dir(dest)
+	if isDir {
+		parent = dest
+	}
+	return dest, os.MkdirAll(parent, 0o755)
+}
+
+// writeArchiveEntry copies the entry name to dir, keeping its modification
+// time so --context-order mtime and stale-file warnings still work.
+func writeArchiveEntry(dir, name string, r io.Reader, modTime time.Time) error {
+	dest, err := archiveEntryPath(dir, name, false)
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if !modTime.IsZero() {
+		os.Chtimes(dest, modTime, modTime)
+	}
+	return nil
+}
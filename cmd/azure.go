@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/spf13/cobra"
+)
+
+// --- Variables for Azure OpenAI flags ---
+var (
+	useAzure        bool   // Flag to send requests to Azure OpenAI instead of OpenRouter/OpenAI
+	azureEndpoint   string // Flag for the resource endpoint, e.g. https://myres.openai.azure.com
+	azureDeployment string // Flag for the model deployment name
+	azureAPIVersion string // Flag for the api-version query parameter
+)
+
+const (
+	azureAPIKeyEnvVar      = "AZURE_OPENAI_API_KEY"
+	defaultAzureAPIVersion = "2024-10-21"
+)
+
+// azureConfig is a resolved Azure OpenAI deployment.
+type azureConfig struct {
+	endpoint   string
+	deployment string
+	apiVersion string
+	apiKey     string
+}
+
+// resolveAzureConfig combines the --azure-* flags with their AZURE_OPENAI_*
+// environment variables (flags win) and checks nothing is missing.
+func resolveAzureConfig() (azureConfig, error) {
+	firstSet := func(values ...string) string {
+		for _, v := range values {
+			if v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+	cfg := azureConfig{
+		endpoint:   strings.TrimRight(firstSet(azureEndpoint, os.Getenv("AZURE_OPENAI_ENDPOINT")), "/"),
+		deployment: firstSet(azureDeployment, os.Getenv("AZURE_OPENAI_DEPLOYMENT")),
+		apiVersion: firstSet(azureAPIVersion, os.Getenv("AZURE_OPENAI_API_VERSION"), defaultAzureAPIVersion),
+		apiKey:     os.Getenv(azureAPIKeyEnvVar),
+	}
+	switch {
+	case cfg.endpoint == "":
+		return cfg, fmt.Errorf("--azure needs an endpoint: pass --azure-endpoint or set AZURE_OPENAI_ENDPOINT")
+	case cfg.deployment == "":
+		return cfg, fmt.Errorf("--azure needs a deployment: pass --azure-deployment or set AZURE_OPENAI_DEPLOYMENT")
+	case cfg.apiKey == "":
+		return cfg, fmt.Errorf("API key not found. Please set the %s environment variable", azureAPIKeyEnvVar)
+	}
+	return cfg, nil
+}
+
+// chatCompletionsURL returns the deployment's chat completions URL.
+func (c azureConfig) chatCompletionsURL() string {
+	return fmt.Sprintf("%s/openai/deployments/%s/chat/completions?api-version=%s",
+		c.endpoint, url.PathEscape(c.deployment), url.QueryEscape(c.apiVersion))
+}
+
+// chatEndpoint returns the endpoint code sends requests to under --azure.
+func (c azureConfig) chatEndpoint() chatEndpoint {
+	return chatEndpoint{name: "Azure OpenAI", url: c.chatCompletionsURL(), headers: map[string]string{"api-key": c.apiKey}, keyEnv: azureAPIKeyEnvVar}
+}
+
+// openAIClient returns a go-openai client for the deployment, used by gen's merge step.
+func (c azureConfig) openAIClient() *openai.Client {
+	config := openai.DefaultAzureConfig(c.apiKey, c.endpoint)
+	config.APIVersion = c.apiVersion
+	config.AzureModelMapperFunc = func(string) string { return c.deployment }
+	config.HTTPClient = newHTTPClient(0)
+	return openai.NewClientWithConfig(config)
+}
+
+// addAzureFlags registers the Azure OpenAI flags shared by code and gen.
+func addAzureFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&useAzure, "azure", false, "Use an Azure OpenAI deployment (key from "+azureAPIKeyEnvVar+")")
+	cmd.Flags().StringVar(&azureEndpoint, "azure-endpoint", "", "Azure OpenAI resource endpoint, e.g. https://myres.openai.azure.com (default $AZURE_OPENAI_ENDPOINT)")
+	cmd.Flags().StringVar(&azureDeployment, "azure-deployment", "", "Azure OpenAI deployment name (default $AZURE_OPENAI_DEPLOYMENT)")
+	cmd.Flags().StringVar(&azureAPIVersion, "azure-api-version", "", "Azure OpenAI api-version (default $AZURE_OPENAI_API_VERSION or "+defaultAzureAPIVersion+")")
+}
@@ -0,0 +1,279 @@
+package cmd
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --- Variables for context budget flags ---
+var (
+	tokenBudget        int    // Flag for the estimated token limit of the file context (0 = unlimited)
+	compressOverBudget bool   // Flag to summarize files instead of dropping them when over budget
+	compressModel      string // Flag for the cheap model that writes the summaries
+	contextHighlight   bool   // Flag to list the focused files on stderr after gathering
+)
+
+var langWeight langWeights // Flag for per-extension weights; lower weights are trimmed first
+
+var contextReportDest string // Flag for where to write the per-file token report ("-" = stderr)
+
+const (
+	defaultCompressModel = "openai/gpt-4o-mini"
+	maxSummaryInput      = 32 * 1024 // Bytes of a file sent to be summarized
+	maxSummaryLength     = 200       // Bytes kept of a summary, in case the model rambles
+)
+
+// langWeights maps file extensions (without the dot) to how much they matter
+// when trimming to --token-budget. Extensions not listed weigh 1.
+type langWeights map[string]float64
+
+func (w *langWeights) String() string {
+	var parts []string
+	for ext, weight := range *w {
+		parts = append(parts, fmt.Sprintf("%s=%g", ext, weight))
+	}
+	slices.Sort(parts)
+	return strings.Join(parts, ",")
+}
+
+func (w *langWeights) Set(s string) error {
+	if *w == nil {
+		*w = langWeights{}
+	}
+	for _, pair := range strings.Split(s, ",") {
+		ext, value, ok := strings.Cut(pair, "=")
+		weight, err := strconv.ParseFloat(value, 64)
+		if !ok || err != nil || weight < 0 {
+			return fmt.Errorf("invalid weight %q: want ext=weight, e.g. ts=0.3", pair)
+		}
+		(*w)[strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))] = weight
+	}
+	return nil
+}
+
+func (w *langWeights) Type() string {
+	return "weights"
+}
+
+// of returns the weight of the file at path.
+func (w langWeights) of(path string) float64 {
+	if weight, ok := w[strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))]; ok {
+		return weight
+	}
+	return 1
+}
+
+// estimateTokens roughly converts a byte count to tokens (~4 bytes per token for code).
+func estimateTokens(bytes int) int {
+	return bytes / 4
+}
+
+// contextTokens estimates the tokens the files take up in the context.
+func contextTokens(files []gatheredFile) int {
+	total := 0
+	for _, f := range files {
+		total += len(f.path) + len(f.content)
+	}
+	return estimateTokens(total)
+}
+
+// fitTokenBudget shrinks the context to --token-budget by dropping the least
+// relevant files or, with --compress-over-budget, replacing them with a
+// one-line summary written by --compress-model. Least relevant means not a
+// --file and not named in the prompt, lowest --lang-weight first, then (with
+// --context-order-by-relevance) lowest relevance score, then largest first,
+// since those free the most room.
+func fitTokenBudget(files []gatheredFile, prompt string, endpoint chatEndpoint) []gatheredFile {
+	total := contextTokens(files)
+	if tokenBudget <= 0 || total <= tokenBudget {
+		return files
+	}
+	logf("Context is ~%d tokens, over the --token-budget of %d.\n", total, tokenBudget)
+
+	candidates := make([]int, 0, len(files))
+	for i, f := range files {
+		if !isFocusedFile(f.path, prompt) {
+			candidates = append(candidates, i)
+		}
+	}
+	var scores []int
+	if orderByRelevance {
+		scores = relevanceScores(files, prompt)
+	}
+	slices.SortStableFunc(candidates, func(a, b int) int {
+		if c := cmp.Compare(langWeight.of(files[a].path), langWeight.of(files[b].path)); c != 0 {
+			return c
+		}
+		if scores != nil && scores[a] != scores[b] {
+			return cmp.Compare(scores[a], scores[b])
+		}
+		return len(files[b].content) - len(files[a].content)
+	})
+
+	dropped := map[int]bool{}
+	for _, i := range candidates {
+		if total <= tokenBudget {
+			break
+		}
+		before := estimateTokens(len(files[i].path) + len(files[i].content))
+		if compressOverBudget {
+			summary, err := summarizeFile(endpoint, files[i])
+			if err == nil && len(summary) >= len(files[i].content) {
+				err = fmt.Errorf("summary is no shorter than the file")
+			}
+			if err == nil {
+				files[i].content = []byte(summary)
+				total += estimateTokens(len(files[i].path)+len(summary)) - before
+				verbosef("Summarized %s\n", files[i].path)
+				continue
+			}
+			warnf("budget", "Failed to summarize %s, dropping it: %v\n", files[i].path, err)
+		}
+		dropped[i] = true
+		total -= before
+		verbosef("Dropped %s to fit the token budget\n", files[i].path)
+	}
+
+	var kept []gatheredFile
+	for i, f := range files {
+		if !dropped[i] {
+			kept = append(kept, f)
+		}
+	}
+	if total > tokenBudget {
+		warnf("budget", "Context is still ~%d tokens; only --file and files named in the prompt are left.\n", total)
+	} else {
+		logf("Context reduced to ~%d tokens (%d file(s) dropped).\n", total, len(dropped))
+	}
+	return kept
+}
+
+// isFocusedFile reports whether path was given with --file or its base name
+// appears in the prompt as a word of its own (so "a.go" doesn't match "data.go"
+// or "a.golden"); such files are never dropped or summarized.
+func isFocusedFile(path, prompt string) bool {
+	name := filepath.Base(path)
+	for _, f := range forcedFiles {
+		if filepath.Base(f) == name {
+			return true
+		}
+	}
+	return mentionPattern(name).MatchString(prompt)
+}
+
+// mentionPattern matches name where it stands alone in a prompt: at the start
+// or after a space, slash, quote or bracket, and at the end or before a space,
+// quote, bracket or punctuation.
+func mentionPattern(name string) *regexp.Regexp {
+	return regexp.MustCompile("(^|[\\s/`'\"(])" + regexp.QuoteMeta(name) + "($|[\\s`'\"),.:;!?])")
+}
+
+// printContextHighlight lists on stderr the files isFocusedFile picks out,
+// followed by the number of supporting files, so a mistyped path in the
+// prompt shows up as a missing entry before the request is sent.
+func printContextHighlight(files []gatheredFile, prompt string) {
+	var focused []string
+	for _, f := range files {
+		if isFocusedFile(f.path, prompt) {
+			focused = append(focused, f.path)
+		}
+	}
+	if len(focused) == 0 {
+		fmt.Fprintln(os.Stderr, "Focused files: none (no --file given and no collected file is named in the request)")
+	} else {
+		fmt.Fprintln(os.Stderr, "Focused files:")
+		for _, path := range focused {
+			fmt.Fprintf(os.Stderr, "  * %s\n", path)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Supporting files: %d\n", len(files)-len(focused))
+}
+
+// writeContextReport writes a table of each file's estimated tokens, largest
+// first with its share of the context, to stderr (dest "-") or the file dest,
+// so it is clear which files to exclude or trim to stay in budget.
+func writeContextReport(dest string, files []gatheredFile) error {
+	type fileTokens struct {
+		path   string
+		tokens int
+	}
+	rows := make([]fileTokens, 0, len(files))
+	total, width := 0, len("TOKENS")
+	for _, f := range files {
+		tokens := estimateTokens(len(f.path) + len(f.content)) // As counted by contextTokens
+		rows = append(rows, fileTokens{f.path, tokens})
+		total += tokens
+		width = max(width, len(strconv.Itoa(tokens)))
+	}
+	slices.SortStableFunc(rows, func(a, b fileTokens) int { return cmp.Compare(b.tokens, a.tokens) })
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Context report: ~%d tokens in %d file(s)\n", total, len(files))
+	fmt.Fprintf(&b, "%*s  %6s  %s\n", width, "TOKENS", "SHARE", "FILE")
+	for _, r := range rows {
+		share := 0.0
+		if total > 0 {
+			share = 100 * float64(r.tokens) / float64(total)
+		}
+		fmt.Fprintf(&b, "%*d  %5.1f%%  %s\n", width, r.tokens, share, r.path)
+	}
+
+	if dest == "-" {
+		fmt.Fprint(os.Stderr, b.String())
+		return nil
+	}
+	if err := os.WriteFile(dest, []byte(b.String()), 0o644); err != nil {
+		return fmt.Errorf("failed to write context report %s: %w", dest, err)
+	}
+	logf("Wrote the context report to %s\n", dest)
+	return nil
+}
+
+// summarizeFile asks --compress-model for a one-line description of f and
+// returns it as a comment in f's language.
+func summarizeFile(endpoint chatEndpoint, f gatheredFile) (string, error) {
+	content := f.content
+	if len(content) > maxSummaryInput {
+		content = content[:maxSummaryInput]
+	}
+	payload := map[string]interface{}{
+		"model": compressModel,
+		"messages": []message{
+			{Role: "system", Content: "Describe what the given source file does in one line of at most 20 words. Reply with the description only."},
+			{Role: "user", Content: fmt.Sprintf("File: %s\n\n%s", f.path, content)},
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	resp, err := postChatCompletion(ctx, newHTTPClient(0), endpoint, payload)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var decoded openRouterResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return "", fmt.Errorf("failed to decode summary response: %w", err)
+	}
+	if len(decoded.Choices) == 0 || strings.TrimSpace(decoded.Choices[0].Message.Content) == "" {
+		return "", fmt.Errorf("empty summary")
+	}
+	line := strings.Join(strings.Fields(decoded.Choices[0].Message.Content), " ")
+	if len(line) > maxSummaryLength {
+		line = line[:maxSummaryLength] + "..."
+	}
+	line = "summary: " + line
+	style, ok := commentStyleFor(f.path)
+	if !ok {
+		style = commentStyle{prefix: "//"}
+	}
+	return style.wrap(line) + "\n", nil
+}
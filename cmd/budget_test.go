@@ -0,0 +1,29 @@
+package cmd
+
+import "testing"
+
+func TestIsFocusedFile(t *testing.T) {
+	tests := []struct {
+		path   string
+		prompt string
+		want   bool
+	}{
+		{"/repo/a.go", "fix the bug in a.go", true},
+		{"/repo/a.go", "a.go panics on empty input", true},
+		{"/repo/lib/a.go", "look at lib/a.go, it panics", true},
+		{"/repo/a.go", "why does `a.go` fail?", true},
+		{"/repo/a.go", `rename "a.go" to b.go`, true},
+		{"/repo/a.go", "(see a.go)", true},
+		{"/repo/a.go", "is the bug in a.go?", true},
+		{"/repo/a.go", "fix the bug in data.go", false},
+		{"/repo/a.go", "update a.golden to match", false},
+		{"/repo/a.go", "no files named here", false},
+		{"/repo/main.go", "check domain.go and main.gone", false},
+		{"/repo/a+b.go", "what does a+b.go do", true},
+	}
+	for _, tt := range tests {
+		if got := isFocusedFile(tt.path, tt.prompt); got != tt.want {
+			t.Errorf("isFocusedFile(%q, %q) = %v, want %v", tt.path, tt.prompt, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// responseCacheEntry is a cached LLM response stored under the user's cache directory.
+type responseCacheEntry struct {
+	Model     string    `json:"model"`
+	CreatedAt time.Time `json:"created_at"`
+	Choices   []string  `json:"choices"`
+}
+
+// responseCacheDir returns the directory cached responses live in
+// (~/.cache/vibe/responses on Linux).
+func responseCacheDir() (string, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate cache directory: %w", err)
+	}
+	return filepath.Join(base, "vibe", "responses"), nil
+}
+
+// responseCacheKey hashes the parts that determine a response into a cache key.
+func responseCacheKey(parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write([]byte(p))
+		h.Write([]byte{0}) // Separator so ("ab","c") and ("a","bc") differ
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// loadCachedResponse returns the cached entry for key if it exists and is younger than ttl.
+func loadCachedResponse(key string, ttl time.Duration) (*responseCacheEntry, bool) {
+	dir, err := responseCacheDir()
+	if err != nil {
+		return nil, false
+	}
+	data, err := os.ReadFile(filepath.Join(dir, key+".json"))
+	if err != nil {
+		return nil, false
+	}
+	var entry responseCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || len(entry.Choices) == 0 {
+		return nil, false
+	}
+	if ttl > 0 && time.Since(entry.CreatedAt) > ttl {
+		return nil, false
+	}
+	return &entry, true
+}
+
+// saveCachedResponse writes entry to the cache under key.
+func saveCachedResponse(key string, entry responseCacheEntry) error {
+	dir, err := responseCacheDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create cache directory %s: %w", dir, err)
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache entry: %w", err)
+	}
+	return os.WriteFile(filepath.Join(dir, key+".json"), data, 0o644)
+}
+
+// replayCachedResponse prints a cached response. When stream is true the text
+// is printed a few characters at a time so it feels like a live response.
+func replayCachedResponse(entry *responseCacheEntry, stream bool) {
+	if len(entry.Choices) > 1 {
+		fmt.Println(formatChoices(entry.Choices))
+		return
+	}
+	content := entry.Choices[0]
+	if !stream {
+		fmt.Println(content)
+		return
+	}
+	const chunkSize = 8
+	runes := []rune(content)
+	for i := 0; i < len(runes); i += chunkSize {
+		end := min(i+chunkSize, len(runes))
+		fmt.Print(string(runes[i:end]))
+		time.Sleep(2 * time.Millisecond)
+	}
+	if !strings.HasSuffix(content, "\n") {
+		fmt.Println()
+	}
+}
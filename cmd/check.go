@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+)
+
+// --- Variables for check flags ---
+var (
+	checkCommand  string // Flag for a command run in the target directory after --apply
+	checkRollback bool   // Flag to restore the applied files when the --check command fails
+)
+
+// defaultCheckCommand is what a bare --check runs.
+const defaultCheckCommand = "go build ./..."
+
+// checkApplied runs the --check command in root once the files in applied
+// have been written, reporting whether it passed. On failure the files are
+// rolled back with --check-rollback, and an error is returned either way so
+// that the exit status reflects the broken build.
+func checkApplied(root string, applied []appliedEdit) error {
+	if checkCommand == "" || len(applied) == 0 {
+		return nil
+	}
+	logf("Checking the applied changes with: %s\n", checkCommand)
+	c := shellCommand(checkCommand)
+	c.Dir = root
+	c.Stdout = os.Stderr // Keep stdout for the response
+	c.Stderr = os.Stderr
+	err := c.Run()
+	if err == nil {
+		logln("✅ Check passed.")
+		return nil
+	}
+	if !checkRollback {
+		return fmt.Errorf("--check %q failed after applying %d file(s) (backups are in *.vibe.bak; add --check-rollback to restore them automatically): %w", checkCommand, len(applied), err)
+	}
+	if rbErr := rollbackApplied(applied); rbErr != nil {
+		return fmt.Errorf("--check %q failed (%v), and rolling back failed: %w", checkCommand, err, rbErr)
+	}
+	return fmt.Errorf("--check %q failed, so the %d applied file(s) were rolled back: %w", checkCommand, len(applied), err)
+}
+
+// rollbackApplied undoes applyEdits: files that existed are restored from
+// their .vibe.bak backups, and files it created are removed.
+func rollbackApplied(applied []appliedEdit) error {
+	for _, e := range applied {
+		if e.backedUp {
+			if err := os.Rename(e.target+".vibe.bak", e.target); err != nil {
+				return fmt.Errorf("failed to restore %s: %w", e.path, err)
+			}
+		} else if err := os.Remove(e.target); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", e.path, err)
+		}
+		logf("Rolled back %s\n", e.path)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/atotto/clipboard"
+	"github.com/pkg/browser"
+)
+
+// isWSLKernel reports whether a /proc/version string belongs to a Windows
+// Subsystem for Linux kernel (both WSL1 and WSL2 mention "microsoft").
+func isWSLKernel(procVersion string) bool {
+	return strings.Contains(strings.ToLower(procVersion), "microsoft")
+}
+
+// isRunningUnderWSL checks whether vibe is running inside WSL.
+func isRunningUnderWSL() bool {
+	if runtime.GOOS != "linux" {
+		return false
+	}
+	if os.Getenv("WSL_DISTRO_NAME") != "" {
+		return true
+	}
+	data, err := os.ReadFile("/proc/version")
+	return err == nil && isWSLKernel(string(data))
+}
+
+// copyToClipboard copies content to the local clipboard. Under WSL it pipes the
+// content into Windows' clip.exe, because atotto/clipboard needs an X11/Wayland
+// clipboard tool that WSL usually doesn't have.
+func copyToClipboard(content string, inWSL bool) error {
+	if !inWSL {
+		return clipboard.WriteAll(content)
+	}
+	c := exec.Command("clip.exe")
+	c.Stdin = strings.NewReader(content)
+	if out, err := c.CombinedOutput(); err != nil {
+		return fmt.Errorf("clip.exe failed: %w %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// readClipboard returns the local clipboard contents. Under WSL it asks
+// Windows' PowerShell, for the same reason copyToClipboard uses clip.exe.
+func readClipboard(inWSL bool) (string, error) {
+	if !inWSL {
+		return clipboard.ReadAll()
+	}
+	out, err := exec.Command("powershell.exe", "-NoProfile", "-Command", "Get-Clipboard -Raw").Output()
+	if err != nil {
+		return "", fmt.Errorf("powershell.exe Get-Clipboard failed: %w", err)
+	}
+	return strings.ReplaceAll(string(out), "\r\n", "\n"), nil
+}
+
+// openURL opens url in the user's browser. Under WSL it opens the Windows
+// browser via wslview (from wslu) if installed, or cmd.exe otherwise.
+func openURL(url string, inWSL bool) error {
+	if !inWSL {
+		return browser.OpenURL(url)
+	}
+	if path, err := exec.LookPath("wslview"); err == nil {
+		return exec.Command(path, url).Run()
+	}
+	return exec.Command("cmd.exe", "/c", "start", "", url).Run()
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import "testing"
+
+func TestIsWSLKernel(t *testing.T) {
+	tests := []struct {
+		name        string
+		procVersion string
+		want        bool
+	}{
+		{
+			name:        "WSL1",
+			procVersion: "Linux version 4.4.0-19041-Microsoft (Microsoft@Microsoft.com) (gcc version 5.4.0 (GCC) ) #1237-Microsoft Sat Sep 11 14:32:00 PST 2021",
+			want:        true,
+		},
+		{
+			name:        "WSL2",
+			procVersion: "Linux version 5.15.153.1-microsoft-standard-WSL2 (root@941d701f84f1) (gcc (GCC) 12.2.0, GNU ld (GNU Binutils) 2.40) #1 SMP Fri Mar 29 23:14:13 UTC 2024",
+			want:        true,
+		},
+		{
+			name:        "plain Linux",
+			procVersion: "Linux version 6.8.0-45-generic (buildd@lcy02-amd64-115) (x86_64-linux-gnu-gcc-13 (Ubuntu 13.2.0-23ubuntu4) 13.2.0, GNU ld (GNU Binutils for Ubuntu) 2.42) #45-Ubuntu SMP PREEMPT_DYNAMIC Fri Aug 30 12:02:04 UTC 2024",
+			want:        false,
+		},
+		{
+			name:        "empty",
+			procVersion: "",
+			want:        false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isWSLKernel(tt.procVersion); got != tt.want {
+				t.Errorf("isWSLKernel(%q) = %v, want %v", tt.procVersion, got, tt.want)
+			}
+		})
+	}
+}
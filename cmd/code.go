@@ -3,34 +3,92 @@ package cmd
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"io/fs"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/daviddl9/vibe/internal/version"
 	"github.com/spf13/cobra"
 )
 
 const (
 	openRouterAPIURL = "https://openrouter.ai/api/v1/chat/completions"
 	// Model updated as per previous user code
-	defaultModel   = "anthropic/claude-3.5-sonnet"
-	apiKeyEnvVar   = "OPENROUTER_API_KEY"
-	commandVersion = "vibe-code/0.1.1"                  // Incremented version slightly
-	projectURL     = "https://github.com/daviddl9/vibe" // Project URL from previous user code
+	defaultModel = "anthropic/claude-3.5-sonnet"
+	apiKeyEnvVar = "OPENROUTER_API_KEY"
+	projectURL   = "https://github.com/daviddl9/vibe" // Project URL from previous user code
+
+	maxResumeAttempts = 3 // Reconnects --resume makes before giving up
 )
 
 // --- Variables for flags ---
 var (
 	llmModel string
 	noStream bool // Flag to DISABLE streaming (streaming is now default)
+	noHeader bool // Flag to suppress the response banners on stdout
+
+	fallbackModel  string   // Flag for a model to retry with once if the request fails with a retryable error
+	fallbackModels []string // Flag for OpenRouter's models array, tried in order when --model is unavailable
+	compareModels  []string // Flag for models to stream side by side on the same request
+
+	providerOrder  []string // Flag for OpenRouter upstream provider preference order
+	allowFallbacks bool     // Flag for whether OpenRouter may fall back to other providers
+	dataCollection string   // Flag for OpenRouter's provider data_collection policy ("allow" or "deny")
+	transforms     []string // Flag for OpenRouter's prompt transforms (e.g. middle-out)
+	appReferer     string   // Flag overriding the HTTP-Referer attribution header
+	appTitle       string   // Flag overriding the X-Title attribution header
+
+	completionCount int // Flag for the number of completions to request (OpenRouter's "n")
+	maxTokens       int // Flag for the completion token limit (0 = the model's default)
+
+	reasoningEffort string // Flag for OpenRouter's reasoning effort ("low", "medium" or "high")
+
+	noCache  bool          // Flag to bypass the response cache
+	cacheTTL time.Duration // Flag for how long cached responses stay valid
+
+	htmlOutputFile  string // Flag for exporting the response as standalone HTML
+	saveRequestFile string // Flag for writing the exact request body to a file before sending
+	manifestPath    string // Flag for writing a JSON manifest of the context files
+	streamRawDest   string // Flag for where to copy the raw SSE lines ("-" = stderr)
+
+	contextRole      string   // Flag for which message carries the file context ("system" or "user")
+	contextStyle     string   // Flag for how the file context is framed ("markers", "xml" or "none")
+	contextSeparator string   // Flag for the line placed between files in the context
+	fromClipboard    bool     // Flag to use the clipboard contents as the context instead of walking a directory
+	noContext        bool     // Flag to send the prompt without any file context
+	extraDirs        []string // Flag for more directories to gather context from
+
+	postProcessCmd   string // Flag for a shell command the response is piped through
+	postProcessScope string // Flag for what the command receives: "response" or "blocks"
+	usePager         bool   // Flag to show the response through $PAGER
+
+	streamIdleTimeout time.Duration // Flag for how long a stream may go without data before aborting
+	resumeStream      bool          // Flag to reconnect and continue when a stream drops before finishing
+
+	promptTemplate string            // Flag for a prompt template name in ~/.config/vibe/templates
+	promptPrefix   string            // Flag for text placed before the request (@file to load it)
+	promptSuffix   string            // Flag for text placed after the request (@file to load it)
+	appendSystem   string            // Flag for text added to the end of the system prompt (@file to load it)
+	templateVars   map[string]string // Flag for extra template variables
 )
 
+// openRouterOnlyFlags are code flags that only mean something to OpenRouter.
+var openRouterOnlyFlags = []string{"provider-order", "allow-fallbacks", "data-collection", "transforms",
+	"reasoning-effort", "fallback-model", "models", "compare", "app-referer", "app-title"}
+
+// reasoningEfforts lists the accepted --reasoning-effort values.
+var reasoningEfforts = []string{"low", "medium", "high"}
+
 // --- Structs for API Interaction (Identical to previous version) ---
 
 // openRouterRequest represents the base JSON payload for the OpenRouter API
@@ -42,13 +100,15 @@ type openRouterRequest struct {
 
 // message represents a single message in the chat history
 type message struct {
-	Role    string `json:"role"` // "system", "user", "assistant"
-	Content string `json:"content"`
+	Role      string `json:"role"` // "system", "user", "assistant"
+	Content   string `json:"content"`
+	Reasoning string `json:"reasoning,omitempty"` // Reasoning trace returned by reasoning models
 }
 
 // openRouterResponse represents the expected JSON response for non-streaming requests
 type openRouterResponse struct {
 	ID      string   `json:"id"`
+	Model   string   `json:"model"`
 	Choices []choice `json:"choices"`
 	Usage   usage    `json:"usage"`
 	Error   apiError `json:"error,omitempty"` // Capture potential API errors
@@ -81,7 +141,8 @@ type streamChoice struct {
 
 type streamDelta struct {
 	// Role string `json:"role"` // Sometimes present
-	Content string `json:"content"`
+	Content   string `json:"content"`
+	Reasoning string `json:"reasoning"` // Reasoning trace, for models that expose it
 }
 
 // apiError represents error structure sometimes returned in the JSON body
@@ -104,330 +165,1401 @@ to an LLM via the OpenRouter API (requires OPENROUTER_API_KEY env var).
 
 Output is streamed by default as it arrives from the LLM.
 Use the --no-stream flag to wait for the full response before displaying.
-Renders the final output as Markdown in the terminal.
+Use the --no-header flag to omit the response banners (useful when piping).
+
+The file context is framed with FILE CONTEXT START/END markers by default.
+Use --context-style xml to send <file path="..."> entries instead, which some
+models follow better, or --context-style none for no framing at all.
+
+Use --context-from-git-diff for PR-style help: the context is git diff
+BASE...HEAD for the target directory instead of the whole tree. BASE is --base
+(default main or master) and HEAD is --diff-head. Add --diff-with-files to also
+send the full current content of each changed file.
+
+Use --fallback-model to survive provider outages: if the request fails with a
+rate limit (429), a server error such as 503, or a network error, it is sent once
+more with the fallback model and the substitution is noted on stderr. Responses
+from the fallback model are not cached.
+
+Use --max-cost (or max_cost in config.json), e.g. --max-cost '$0.50', to avoid
+accidentally sending a huge context to an expensive model. The cost is
+estimated from the context size and OpenRouter's prices for the model (plus
+--max-tokens of output when set); above the limit vibe asks before sending,
+or refuses when there is no terminal to ask on. Pass --yes to send anyway.
+
+Use --manifest FILE to keep a record of which files informed a response: a
+JSON manifest of the final context (path, size and sha256 of each file, after
+all filtering and --token-budget trimming) is written before the request is sent.
+
+Use --context-report to see what is using up the context: after all filtering
+and --token-budget trimming, each file's estimated tokens and share of the
+context are listed on stderr, largest first (or written to FILE with
+--context-report=FILE). A generated file taking 40% of the prompt is a good
+candidate for --exclude-glob.
+
+Use --save-request FILE when reporting API problems: the exact JSON body sent
+to the API is written to FILE, pretty-printed, just before the request goes
+out (after a --fallback-model retry it holds the retried body). Headers are not
+written, so the file never contains your API key.
+
+The target directory may also be a .zip, .tar.gz or .tgz archive, which is
+extracted to a temporary directory for the request and removed afterwards.
+--apply is not supported for archives.
+
+Use --context-line-range FILE:START-END (repeatable; ranges such as
+foo.go:10-20,90-99 can be combined and overlapping ones are merged) to send only
+part of a large file, e.g. the function you are debugging. The file is included
+like a --file, and the omitted lines are replaced by notes such as
+"// ... lines 1-99 of 300 omitted ..." so the model knows it sees an excerpt.
+
+Use --dir to gather from several directories that aren't next to each other,
+e.g. two services in a monorepo: --dir services/auth --dir services/billing.
+Each file is labelled with the directory it came from, and a file reached from
+two of them is sent once. With a target directory argument, the --dir
+directories are gathered in addition to it; without one, only they are.
+
+Use --readme-first on unfamiliar repos: the README.md at the root of the target
+directory (read even if the filters skip it) is put at the very top of the
+context, marked as the PROJECT OVERVIEW, so the model gets its bearings before
+reading the code.
+
+Use --preamble-file FILE to put team notes such as coding standards or
+architecture decisions at the start of the file context, under a PREAMBLE
+marker (a <preamble> element with --context-style xml). Unlike --append-system
+it travels with the files, so it follows the context into the user message
+with --context-role user. It comes before a --readme-first README.
+
+Use --footer-file FILE for the opposite end: its content is put after the last
+file, under a FOOTER marker (a <footer> element with xml), for the instruction
+that matters most, which some models follow better when it comes last.
+
+Use --no-context for general questions ("what's the idiomatic way to do X in
+Go?"): no files are gathered and the model gets only its persona and your
+prompt. The target directory is then only used by --apply.
+
+Use --models a,b,c to let OpenRouter fall back on its side: the list is sent as
+the request's "models" array, which OpenRouter tries in order if --model is
+unavailable, and the model that actually served the request is shown on
+stderr. Unlike --fallback-model this needs no second request.
+
+Use --compare a,b to choose between models on a real task: the same context
+and prompt go to each model concurrently and the answers stream in together.
+Comparing two models on a terminal at least 83 columns wide shows them in two
+columns (a row appears once both models have written it); otherwise each line
+is printed as it arrives, labelled with its model. Compared responses are not
+cached, and --max-cost is checked for each model.
+
+Use --stream-raw when chunks fail to decode: every line of the server-sent event
+stream is copied, unparsed, to stderr (or to FILE with --stream-raw=FILE) while
+the response is processed as usual. It is the streaming counterpart to
+--save-request.
+
+Use --edit to compose a longer request comfortably, as with git commit: $EDITOR
+opens on a file for the prompt, and whatever you save (minus lines starting
+with '#', which are left for your notes) is sent with the gathered context. The
+only argument is then the target directory; an empty request aborts.
+
+Use --from-clipboard to ask about snippets you have already copied: the
+clipboard contents become the context and no directory is walked.
+
+Use --strip-blank-lines (drop every blank line) or --collapse-whitespace (keep
+at most one blank line in a row) to cut tokens on loosely formatted files. Only
+whole blank lines are touched, never a line of code; savings are typically a
+few percent, and are reported on stderr. Avoid them with --apply, since the
+model then sees (and may write back) the condensed files.
+
+Use --strip-comments to drop comments from the context, which can save a lot on
+heavily commented code. A small lexer skips strings, so comment-like text in
+them is kept, as are #! lines and //go: directives; lines left empty are
+removed. Supported: Go, JavaScript/TypeScript, Rust, Java, Kotlin, Scala, Swift,
+C/C++, C#, Dart, Protobuf (// and /* */), Python, Ruby, shell, YAML, TOML (#)
+and CSS (/* */); other files are sent unchanged. The model no longer sees the
+comments, so don't combine it with --apply, and expect it to miss intent the
+comments explained.
+
+Use --context-order-by-relevance to put the files that best match the request
+first: each file is scored by how often the prompt's words and identifiers
+(three characters or more, minus common words) appear in it, with a match in
+the file name counting ten times. It is a local heuristic and needs no extra
+API call. Combined with --token-budget, the lowest-scoring files are dropped first.
+
+Use --token-budget to cap the file context at an estimated number of tokens
+(~4 bytes each). When it is exceeded, the least relevant files (not a --file and
+not named in the prompt, largest first) are dropped until it fits. In
+polyglot repos, --lang-weight go=1,ts=0.3 trims lower-weighted extensions
+first (unlisted extensions weigh 1), so generated .ts goes before any .go. With
+--compress-over-budget they are instead replaced by a one-line summary from
+--compress-model, so the model still knows they exist. Each summary is an
+extra (cheap) request that sends up to 32KB of the file, so this costs a little
+money and time on every run that goes over budget.
+
+Use --context-highlight to check what the request is focused on: after
+gathering, the files given with --file or named in the prompt are listed on
+stderr, followed by the number of supporting files. A path you meant to name
+but that isn't listed did not match any collected file.
+
+Use --resume on flaky connections: if the stream drops before the model finishes,
+vibe reconnects (with exponential backoff) and sends the partial answer back
+asking the model to continue. This costs extra tokens, so it is off by default.
+
+For organisations with data-handling rules, --data-collection deny restricts
+OpenRouter to providers that don't retain or train on prompts, and --transforms
+sets OpenRouter's prompt transforms. Usage is attributed to vibe in the
+HTTP-Referer and X-Title headers; use --app-referer and --app-title to attribute
+it to your own app instead.
+
+Use --reasoning-effort with reasoning models to set how much they think before
+answering (sent as OpenRouter's "reasoning" field). If the model returns its
+reasoning trace, it is shown dimmed on stderr, separate from the answer.
+
+Use --post-process to pipe the response through an external command, such as a
+formatter, before it is shown. By default each fenced code block is piped on
+its own (--post-process-scope blocks); the full response is buffered first.
+
+Use --pager to read long responses in $PAGER (less -R if unset), rendered as
+Markdown. The response is buffered instead of streamed, and paging is skipped
+when stdout isn't a terminal.
+
+Use --apply to write the files the model returns back into the target directory.
+The model is asked to label each file's code block with its path (` + "```" + `go lib/a.go);
+existing files are backed up to <file>.vibe.bak first. Add --only-clean to skip
+files with uncommitted git changes (untracked files count as clean), and --force
+to overwrite them anyway. Add --check to run "go build ./..." in the target
+directory afterwards (or --check="CMD" for another command, such as "make
+test"); vibe exits with an error if it fails. With --check-rollback a failed
+check also restores the originals from the backups and removes new files.
+
+Use --format to choose how the response is printed: text (the default, streamed
+as it arrives), markdown (rendered for the terminal once complete) or json (a
+{"model", "cached", "choices"} object, with no banners, for scripts).
+--no-stream is not a format and has no --format equivalent: it asks the API
+for the whole response at once and then prints it in the chosen format, so
+--no-stream alone prints plain text like the default, just not as it arrives.
+--format markdown and json already wait for the complete response, whether or
+not it is streamed. --format is shared with show (where it replaces --render)
+and gen (where it replaces --raw).
+
+Use --stream-buffer-render for the best of both: the raw response streams in
+dimmed as a preview, and once it is complete the preview is erased and the
+response is rendered as Markdown in its place. Reasoning traces aren't shown
+while previewing. When stdout isn't a terminal the response streams as plain
+text instead.
+
+Use --interactive for a conversation instead of a single request:
+  vibe code --interactive [directory]
+gathers the context once, then reads prompts from stdin and streams each answer,
+keeping the conversation history in memory. /reload re-gathers the context,
+/model NAME switches models (aliases work) and /exit (or Ctrl-D) quits. The
+context is gathered as for a single request, so --context-from-git-diff,
+--context-line-range and --token-budget apply.
+
+Use --watch for a live feedback loop: the request re-runs whenever a file that
+passes code's filters changes (debounced by --watch-debounce). Output is appended
+unless --watch-clear is set.
+
+Use --json-schema for structured output instead of Markdown. On its own (or as
+--json-schema=edits) it asks for a JSON object of {"file", "content"} edits,
+which --apply writes directly without parsing code fences. --json-schema=FILE
+uses your own JSON schema instead. Either way the schema is sent as OpenRouter's
+response_format and the response is validated against it.
+
+Use --azure to send the request to an Azure OpenAI deployment instead of
+OpenRouter (requires AZURE_OPENAI_API_KEY). --azure-endpoint, --azure-deployment
+and --azure-api-version default to $AZURE_OPENAI_ENDPOINT,
+$AZURE_OPENAI_DEPLOYMENT and $AZURE_OPENAI_API_VERSION; the deployment takes the
+place of --model, and the OpenRouter-only --provider-order, --allow-fallbacks
+and --reasoning-effort are rejected.
+
+Model aliases can be defined in ~/.config/vibe/config.json:
+  {"aliases": {"sonnet": "anthropic/claude-3.5-sonnet", "gpt4o": "openai/gpt-4o"}}
+Unknown keys in config.json (usually typos) are reported with a warning that
+suggests the nearest valid key, or fail the command with --strict-config.
+
+Use --prompt-prefix and --prompt-suffix to wrap every request in standard text
+(for example "Respond with only the code, no explanation."); either may be @file
+to load the text from a file. Defaults can be set in config.json as
+"prompt_prefix" and "prompt_suffix". Use --append-system to add instructions to
+the system prompt itself without replacing it.
+
+Responses are cached under ~/.cache/vibe/responses, keyed by the prompt, the
+gathered context and the model. Re-running an identical request within
+--cache-ttl replays the stored answer without calling the API; use --no-cache
+to force a fresh query. The key covers the context in the order it is sent, so
+changing --context-order also misses the cache. --compare always queries the
+models and caches nothing.
 
 Example:
   vibe code "add a function in lib/a.go to multiply the Answer by 2" .
   vibe code "refactor main.go to print the result" --no-stream
-  vibe code "explain the main package" ./mygocode -m openai/gpt-4o`,
-	Args: cobra.RangeArgs(1, 2), // Requires 1 (prompt) or 2 (prompt, directory) arguments
+  vibe code "explain the main package" ./mygocode -m openai/gpt-4o
+  vibe code "explain the main package" -m sonnet   # alias from config.json
+  vibe code "review lib/a.go" --provider-order Anthropic --allow-fallbacks=false
+  vibe code --template add-tests --var pkg=lib "" .`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if interactiveMode {
+			return cobra.MaximumNArgs(1)(cmd, args) // Only the directory; prompts come from stdin
+		}
+		if editPrompt {
+			return cobra.MaximumNArgs(1)(cmd, args) // Only the directory; the prompt comes from $EDITOR
+		}
+		return cobra.RangeArgs(1, 2)(cmd, args) // Requires 1 (prompt) or 2 (prompt, directory) arguments
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		userPrompt := args[0]
-		targetDir := "." // Default to current directory
+		if interactiveMode {
+			return runInteractive(cmd, args)
+		}
+		if watchMode {
+			return watchCode(cmd, args)
+		}
+		return runCode(cmd, args)
+	},
+}
+
+// runCode performs a single code request: gather context, query the model and
+// display (and optionally apply) the response.
+func runCode(cmd *cobra.Command, args []string) error {
+	var userPrompt string
+	targetDir := "." // Default to current directory
+	if editPrompt {
+		if len(args) == 1 {
+			targetDir = args[0]
+		}
+	} else {
+		userPrompt = args[0]
 		if len(args) == 2 {
 			targetDir = args[1]
 		}
-
-		// Determine if streaming should be used (default is true unless --no-stream is present)
-		streamOutput := !noStream // <--- Streaming is true if noStream is false
-
-		// --- 1. Get API Key ---
-		apiKey := os.Getenv(apiKeyEnvVar)
-		if apiKey == "" {
-			return fmt.Errorf("API key not found. Please set the %s environment variable", apiKeyEnvVar)
+	}
+	if err := checkPromptNotDirectory(args); err != nil {
+		return err
+	}
+
+	// Determine if streaming should be used (default is true unless --no-stream is present)
+	streamOutput := !noStream // <--- Streaming is true if noStream is false
+
+	if contextRole != "system" && contextRole != "user" {
+		return fmt.Errorf("invalid --context-role %q: must be 'system' or 'user'", contextRole)
+	}
+	if !slices.Contains(contextStyles, contextStyle) {
+		return fmt.Errorf("invalid --context-style %q: must be one of %s", contextStyle, strings.Join(contextStyles, ", "))
+	}
+	if reasoningEffort != "" && !slices.Contains(reasoningEfforts, reasoningEffort) {
+		return fmt.Errorf("invalid --reasoning-effort %q: must be one of %s", reasoningEffort, strings.Join(reasoningEfforts, ", "))
+	}
+	if postProcessScope != "response" && postProcessScope != "blocks" {
+		return fmt.Errorf("invalid --post-process-scope %q: must be 'response' or 'blocks'", postProcessScope)
+	}
+	// The preview needs a terminal to erase; elsewhere the stream prints as usual
+	previewStream := false
+	if streamBufferRender {
+		switch {
+		case !streamOutput:
+			return fmt.Errorf("--stream-buffer-render previews the stream; drop --no-stream")
+		case cmd.Flags().Changed("format") || postProcessCmd != "" || usePager || len(compareModels) > 0:
+			return fmt.Errorf("--stream-buffer-render can't be combined with --format, --post-process, --pager or --compare")
+		case !stdoutIsTerminal():
+			verbosef("Not previewing the stream: stdout is not a terminal\n")
+		default:
+			previewStream = true
+			codeFormat = formatMarkdown // Rendered once complete, replacing the preview
 		}
-
-		// --- 2. Validate Target Directory ---
-		absTargetDir, err := filepath.Abs(targetDir)
+	}
+	// Paging is pointless (and breaks pipes) unless stdout is a terminal
+	pageResponse := usePager && stdoutIsTerminal()
+	if usePager && !pageResponse {
+		verbosef("Not paging the response: stdout is not a terminal\n")
+	}
+	// Post-processing, paging and the markdown and json formats need the
+	// complete response, so don't print it as it arrives
+	bufferOutput := postProcessCmd != "" || pageResponse || codeFormat != formatText
+	if codeFormat == formatJSON {
+		noHeader = true // Keep stdout a single JSON document
+	}
+
+	if completionCount < 1 {
+		return fmt.Errorf("--count must be at least 1, got %d", completionCount)
+	}
+	if completionCount > 1 && streamOutput {
+		return fmt.Errorf("--count > 1 is not supported while streaming; add --no-stream to receive %d completions", completionCount)
+	}
+	if resumeStream && !streamOutput {
+		return fmt.Errorf("--resume only applies to streaming; drop --no-stream")
+	}
+	var schema *responseSchema
+	if jsonSchemaSpec != "" {
+		if postProcessCmd != "" {
+			return fmt.Errorf("--post-process can't be combined with --json-schema")
+		}
+		loaded, err := loadResponseSchema(jsonSchemaSpec)
 		if err != nil {
-			return fmt.Errorf("failed to get absolute path for %s: %w", targetDir, err)
+			return err
+		}
+		schema = loaded
+		if applyChanges && !schema.isEdits() {
+			return fmt.Errorf("--apply with --json-schema needs the built-in %q schema", editsSchemaName)
+		}
+	}
+	if applyChanges && completionCount > 1 {
+		return fmt.Errorf("--apply needs a single completion; drop --count")
+	}
+	if len(compareModels) > 0 {
+		switch {
+		case len(compareModels) < 2:
+			return fmt.Errorf("--compare needs at least two models, e.g. --compare sonnet,openai/gpt-4o")
+		case !streamOutput:
+			return fmt.Errorf("--compare streams the models side by side; drop --no-stream")
+		case applyChanges || schema != nil || postProcessCmd != "" || usePager || completionCount > 1 || htmlOutputFile != "" || codeFormat != formatText:
+			return fmt.Errorf("--compare can't be combined with --apply, --json-schema, --post-process, --pager, --count, --html or --format")
+		}
+	}
+	lineRanges, err := resolveLineRanges()
+	if err != nil {
+		return err
+	}
+	if len(lineRanges) > 0 && applyChanges {
+		return fmt.Errorf("--apply would write back partial files; drop --context-line-range")
+	}
+	if (onlyClean || forceApply) && !applyChanges {
+		return fmt.Errorf("--only-clean and --force only make sense with --apply")
+	}
+	if (checkCommand != "" || checkRollback) && !applyChanges {
+		return fmt.Errorf("--check and --check-rollback only make sense with --apply")
+	}
+	if checkRollback && checkCommand == "" {
+		checkCommand = defaultCheckCommand
+	}
+
+	// Expand short model names from the config's aliases
+	resolvedModel, err := resolveModelAlias(llmModel)
+	if err != nil {
+		return err
+	}
+	llmModel = resolvedModel
+	if fallbackModel != "" {
+		if fallbackModel, err = resolveModelAlias(fallbackModel); err != nil {
+			return err
+		}
+	}
+	for i, m := range fallbackModels {
+		if fallbackModels[i], err = resolveModelAlias(m); err != nil {
+			return err
 		}
-		info, err := os.Stat(absTargetDir)
+	}
+	for i, m := range compareModels {
+		if compareModels[i], err = resolveModelAlias(m); err != nil {
+			return err
+		}
+	}
+
+	// --- 1. Get API Key and endpoint ---
+	endpoint, err := resolveCodeEndpoint(cmd)
+	if err != nil {
+		return err
+	}
+
+	// --- 2. Validate Target Directory ---
+	if isArchive(targetDir) && !noContext {
+		if applyChanges {
+			return fmt.Errorf("--apply can't write changes into an archive; extract %s first", targetDir)
+		}
+		dir, cleanup, err := extractArchive(targetDir)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		targetDir = dir
+	}
+	absTargetDir, err := resolveTargetDir(targetDir)
+	if err != nil {
+		return err
+	}
+
+	if editPrompt {
+		if userPrompt, err = promptFromEditor("", absTargetDir); err != nil {
+			return err
+		}
+	}
+
+	// Expand the prompt through a template if one was requested
+	if promptTemplate != "" {
+		userPrompt, err = renderPromptTemplate(promptTemplate, userPrompt, absTargetDir, templateVars)
 		if err != nil {
-			if os.IsNotExist(err) {
-				return fmt.Errorf("directory not found: %s", absTargetDir)
+			return err
+		}
+		verbosef("Prompt from template %q: %s\n", promptTemplate, userPrompt)
+	}
+	requestText, err := wrapUserPrompt(cmd, userPrompt)
+	if err != nil {
+		return err
+	}
+
+	// --- 3. Gather Context ---
+	files, err := gatherCodeContext(absTargetDir, len(args) == 2 || (editPrompt && len(args) == 1), requestText, lineRanges, endpoint)
+	if err != nil {
+		return err
+	}
+	if manifestPath != "" {
+		if err := writeManifest(manifestPath, userPrompt, files); err != nil {
+			return err
+		}
+	}
+	if contextHighlight {
+		printContextHighlight(files, requestText)
+	}
+	if contextReportDest != "" {
+		if err := writeContextReport(contextReportDest, files); err != nil {
+			return err
+		}
+	}
+
+	preamble, err := loadPreamble()
+	if err != nil {
+		return err
+	}
+	footer, err := loadFooter("")
+	if err != nil {
+		return err
+	}
+
+	// --- 4. Construct LLM Prompt ---
+	systemContent, userContent := buildCodeMessages(formatFileContext(preamble, files, footer, contextStyle, contextSeparator), requestText, contextRole)
+	if noContext {
+		systemContent, userContent = generalSystemPrompt, requestText
+	}
+	if systemContent, err = withAppendedSystem(systemContent); err != nil {
+		return err
+	}
+	switch {
+	case schema != nil && schema.isEdits():
+		userContent += jsonEditsInstructions
+	case applyChanges:
+		userContent += applyInstructions
+	}
+
+	// --- 5. Check Response Cache ---
+	keyParts := []string{systemContent, userContent, llmModel, fmt.Sprint(completionCount)}
+	if reasoningEffort != "" {
+		keyParts = append(keyParts, "reasoning="+reasoningEffort) // Only when set, so existing entries stay valid
+	}
+	if maxTokens > 0 {
+		keyParts = append(keyParts, fmt.Sprintf("max_tokens=%d", maxTokens))
+	}
+	if len(fallbackModels) > 0 {
+		keyParts = append(keyParts, "models="+strings.Join(fallbackModels, ","))
+	}
+	if schema != nil {
+		schemaJSON, _ := json.Marshal(schema.doc)
+		keyParts = append(keyParts, "schema="+string(schemaJSON))
+	}
+	cacheKey := responseCacheKey(keyParts...)
+	useCache := !noCache && len(compareModels) == 0 // A single model's cached answer is no comparison
+	if useCache {
+		if entry, ok := loadCachedResponse(cacheKey, cacheTTL); ok {
+			logf("Using cached response from %s ago (pass --no-cache to re-query %s).\n",
+				time.Since(entry.CreatedAt).Round(time.Second), llmModel)
+			if !noHeader {
+				fmt.Println("\n--- LLM Response ---")
 			}
-			return fmt.Errorf("failed to stat %s: %w", absTargetDir, err)
-		}
-		if !info.IsDir() {
-			return fmt.Errorf("path is not a directory: %s", absTargetDir)
-		}
-
-		// --- 3. Gather Context ---
-		fmt.Fprintf(os.Stderr, "Gathering context from: %s\n", absTargetDir) // Use Stderr for progress
-		var contextBuilder strings.Builder
-		filesCollected := 0
-		skippedDirs := 0
-
-		// Define files/dirs to skip more explicitly
-		skipDirs := map[string]bool{
-			".git":         true,
-			"node_modules": true,
-			"vendor":       true,
-			"__pycache__":  true,
-			"venv":         true,
-			".venv":        true,
-			"target":       true, // Common for Rust/Java
-			"build":        true, // Common build output dir
-		}
-		// Define relevant extensions
-		extensionsToInclude := map[string]bool{
-			".go":           true,
-			".html":         true,
-			".py":           true,
-			".js":           true,
-			".ts":           true,
-			".jsx":          true,
-			".tsx":          true,
-			".rs":           true,
-			".java":         true,
-			".kt":           true,
-			".c":            true,
-			".h":            true,
-			".cpp":          true,
-			".cs":           true,
-			".rb":           true,
-			".php":          true,
-			".md":           true,
-			".yaml":         true,
-			".yml":          true,
-			".toml":         true,
-			".json":         true,
-			"dockerfile":    true, // Match Dockerfile exactly
-			".dockerignore": true,
-			".sh":           true,
-			".sql":          true,
-			".env":          true, ".env.example": true,
-		}
-
-		err = filepath.WalkDir(absTargetDir, func(path string, d fs.DirEntry, walkErr error) error {
-			if walkErr != nil {
-				fmt.Fprintf(os.Stderr, "Warning: Error accessing path %q: %v\n", path, walkErr)
-				if d != nil && d.IsDir() {
-					return filepath.SkipDir // Skip directory if error accessing it
+			if postProcessCmd != "" {
+				entry.Choices = postProcessChoices(entry.Choices)
+			}
+			if pageResponse || codeFormat != formatText {
+				if err := showCodeResponse(entry.Choices, entry.Model, true, pageResponse); err != nil {
+					return err
 				}
-				return nil // Attempt to continue if it was a file error
+			} else {
+				replayCachedResponse(entry, streamOutput && !bufferOutput)
 			}
-
-			// Skip directories, hidden files/dirs based on defined lists
-			if d.IsDir() {
-				dirName := d.Name()
-				if skipDirs[dirName] || (strings.HasPrefix(dirName, ".") && dirName != ".") {
-					skippedDirs++
-					return filepath.SkipDir
+			if !noHeader {
+				fmt.Println("--------------------")
+			}
+			if htmlOutputFile != "" {
+				if err := writeHTMLExport(htmlOutputFile, "vibe: "+userPrompt, formatChoices(entry.Choices)); err != nil {
+					return err
 				}
-				return nil // Continue walking into non-skipped directories
 			}
+			recordHistory(historyEntry{Command: "code", Model: entry.Model, Prompt: userPrompt, Files: len(files), Cached: true,
+				Response: formatChoices(entry.Choices)}, len(systemContent)+len(userContent))
+			if applyChanges && len(entry.Choices) > 0 {
+				return applyResponse(absTargetDir, entry.Choices[0])
+			}
+			return nil
+		}
+	}
+
+	// --- 6. Make API Call ---
+	sendModels := []string{llmModel}
+	if len(compareModels) > 0 {
+		sendModels = compareModels
+	}
+	for _, model := range sendModels {
+		if err := confirmCost(cmd, endpoint, model, len(systemContent)+len(userContent)); err != nil {
+			return err
+		}
+	}
+	// Use the determined streamOutput value here
+	if len(compareModels) > 0 {
+		logf("Sending request to %s models: %s...\n", endpoint.name, strings.Join(compareModels, ", "))
+	} else {
+		logf("Sending request to %s model: %s (Streaming: %v)...\n", endpoint.name, llmModel, streamOutput)
+	}
+
+	requestPayload := openRouterRequest{
+		Model: llmModel,
+		Messages: []message{
+			{Role: "system", Content: systemContent},
+			{Role: "user", Content: userContent},
+		},
+	}
+
+	// Marshal base payload first
+	payloadBytes, err := json.Marshal(requestPayload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal base request payload: %w", err)
+	}
+
+	// Use a map to easily add the 'stream' field conditionally
+	finalPayloadMap := map[string]interface{}{}
+	if err := json.Unmarshal(payloadBytes, &finalPayloadMap); err != nil {
+		return fmt.Errorf("failed to unmarshal payload to map: %w", err)
+	}
+	// Add stream field based on the streamOutput variable
+	if streamOutput {
+		finalPayloadMap["stream"] = true
+	} // No need for 'else', default is false / field absent
+
+	// Add OpenRouter provider routing preferences if requested
+	providerPrefs, err := buildProviderPreferences(cmd)
+	if err != nil {
+		return err
+	}
+	if providerPrefs != nil {
+		finalPayloadMap["provider"] = providerPrefs
+	}
+	if cmd.Flags().Changed("transforms") {
+		finalPayloadMap["transforms"] = transforms // An empty list turns off OpenRouter's defaults
+	}
+	if completionCount > 1 {
+		finalPayloadMap["n"] = completionCount
+	}
+	if schema != nil {
+		finalPayloadMap["response_format"] = schema.responseFormat()
+	}
+	if reasoningEffort != "" {
+		finalPayloadMap["reasoning"] = map[string]string{"effort": reasoningEffort}
+	}
+	if maxTokens > 0 {
+		finalPayloadMap["max_tokens"] = maxTokens
+	}
+	if len(fallbackModels) > 0 {
+		finalPayloadMap["models"] = fallbackModels
+	}
+
+	// Cancelled by the stream idle timer if the model stalls mid-stream
+	reqCtx, cancelReq := context.WithCancel(context.Background())
+	defer cancelReq()
+
+	closeRawStream, err := openRawStream()
+	if err != nil {
+		return err
+	}
+	defer closeRawStream()
+	if len(compareModels) > 0 {
+		return runCompare(endpoint, finalPayloadMap)
+	}
+	if err := saveRequest(finalPayloadMap); err != nil {
+		return err
+	}
+	client := newHTTPClient(180 * time.Second) // Reasonable timeout
+	resp, err := postChatCompletion(reqCtx, client, endpoint, finalPayloadMap)
+	usedFallback := false
+	if err != nil && fallbackModel != "" && isRetryableError(err) {
+		warnf("fallback", "%v\nRetrying once with fallback model %s...\n", err, fallbackModel)
+		finalPayloadMap["model"] = fallbackModel
+		llmModel = fallbackModel
+		usedFallback = true
+		if err := saveRequest(finalPayloadMap); err != nil {
+			return err
+		}
+		resp, err = postChatCompletion(reqCtx, client, endpoint, finalPayloadMap)
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// --- 7. Display Result ---
+	if !noHeader {
+		fmt.Println("\n--- LLM Response ---") // Print header to Stdout
+	}
+	var responseChoices []string // Collected for the response cache and exports
+	servedBy := ""               // The model OpenRouter routed to, when it says
+	cacheable := !usedFallback   // The cache key names the primary model
+	var tokenUsage usage         // Reported for non-streaming responses only
+	if streamOutput {
+		// == Streaming Logic ==
+		var preview *streamPreview
+		if previewStream {
+			preview = newStreamPreview()
+		}
+		result := readStream(resp.Body, cancelReq, bufferOutput, preview)
+		streamed := result.content
+		streamErrorOccurred := result.errored
+
+		// Reconnect and ask the model to carry on if the stream dropped (--resume)
+		for attempt := 1; resumeStream && !result.finished && result.err == nil && attempt <= maxResumeAttempts; attempt++ {
+			delay := time.Second << (attempt - 1)
+			if preview != nil {
+				preview.clear() // Redrawn below the warning, so the row count stays right
+			}
+			warnf("resume", "\nStream ended before the model finished; resuming in %s (attempt %d of %d)...\n", delay, attempt, maxResumeAttempts)
+			if preview != nil {
+				preview.write(streamed)
+			}
+			time.Sleep(delay)
+
+			finalPayloadMap["messages"] = continuationMessages(requestPayload.Messages, streamed)
+			resumeCtx, cancelResume := context.WithCancel(context.Background())
+			resumeResp, err := postChatCompletion(resumeCtx, client, endpoint, finalPayloadMap)
+			if err != nil {
+				cancelResume()
+				warnf("resume", "Resume request failed: %v\n", err)
+				continue
+			}
+			result = readStream(resumeResp.Body, cancelResume, bufferOutput, preview)
+			resumeResp.Body.Close()
+			cancelResume()
+			streamed += result.content
+			streamErrorOccurred = streamErrorOccurred || result.errored
+		}
 
-			// Skip hidden files (allow specific dotfiles like .env)
-			if strings.HasPrefix(d.Name(), ".") && !extensionsToInclude[d.Name()] {
-				return nil
+		if result.stalled && !result.finished {
+			// Keep what was already printed, but fail clearly instead of hanging
+			fmt.Println()
+			return fmt.Errorf("stream stalled: no data received for %s (partial output shown above; adjust with --stream-idle-timeout)", streamIdleTimeout)
+		}
+		if result.err != nil {
+			// A reported failure isn't a dropped connection, so it isn't resumed or cached
+			fmt.Println()
+			if streamed != "" && !bufferOutput {
+				return fmt.Errorf("%w (partial output shown above)", result.err)
 			}
+			return result.err
+		}
+		if !bufferOutput {
+			fmt.Println() // Add a newline after streaming is done / before rendering
+		}
+		if preview != nil {
+			preview.clear() // The rendered response takes its place below
+		}
 
-			// Include files based on extension map or exact name matches
-			include := false
-			fileNameLower := strings.ToLower(d.Name())
-			fileExtLower := strings.ToLower(filepath.Ext(fileNameLower))
+		if streamErrorOccurred {
+			fmt.Fprintln(os.Stderr, "Note: Errors occurred during streaming. Output may be incomplete.")
+			cacheable = false
+		} else if result.reason == "length" {
+			warnTruncated(0)
+		} else if !result.finished {
+			fmt.Fprintln(os.Stderr, "Note: The stream ended before the model finished. Output may be incomplete (use --resume to reconnect).")
+			cacheable = false
+		}
+		servedBy = result.model
+		if streamed != "" {
+			responseChoices = []string{streamed}
+		} else if !streamErrorOccurred {
+			// Mirror the non-streaming warning, or the banners just enclose nothing
+			warnf("empty-response", "Received an empty streaming response from the LLM.\n")
+		}
+	} else {
+		// == Non-Streaming Logic ==
+		var openRouterResp openRouterResponse
+		bodyBytes, readErr := io.ReadAll(resp.Body)
+		if readErr != nil {
+			return fmt.Errorf("failed to read non-streaming response body: %w", readErr)
+		}
 
-			if extensionsToInclude[fileExtLower] || extensionsToInclude[fileNameLower] {
-				include = true
-			}
+		if err := json.Unmarshal(bodyBytes, &openRouterResp); err != nil {
+			return fmt.Errorf("failed to decode non-streaming %s response: %w. Body: %s", endpoint.name, err, string(bodyBytes))
+		}
 
-			if !include {
-				return nil // Skip files not matching criteria
-			}
+		if openRouterResp.Error.Message != "" {
+			return fmt.Errorf("received API error: Type=%s, Message=%s", openRouterResp.Error.Type, openRouterResp.Error.Message)
+		}
+		servedBy = openRouterResp.Model
+		tokenUsage = openRouterResp.Usage
 
-			// Get absolute path for consistency in context
-			absPath, _ := filepath.Abs(path) // Ignore error here, fallback below if needed
-			if absPath == "" {
-				absPath = path // Fallback
+		if len(openRouterResp.Choices) == 0 || openRouterResp.Choices[0].Message.Content == "" {
+			warnf("empty-response", "Received an empty non-streaming response from the LLM.\n")
+		} else {
+			for _, c := range openRouterResp.Choices {
+				if c.Message.Reasoning != "" {
+					logln(dimText(c.Message.Reasoning))
+				}
+				responseChoices = append(responseChoices, c.Message.Content)
+				if c.FinishReason == "length" {
+					warnTruncated(len(responseChoices))
+				}
 			}
-
-			// Avoid reading excessively large files (e.g., > 5MB)
-			fileInfo, statErr := d.Info()
-			if statErr == nil && fileInfo.Size() > 5*1024*1024 {
-				fmt.Fprintf(os.Stderr, "Warning: Skipping large file %s (>5MB)\n", path)
-				return nil
+			if !bufferOutput {
+				// Print raw content directly, labelling each completion if there are several (--count)
+				fmt.Println(formatChoices(responseChoices))
 			}
+		}
+	}
 
-			content, readErr := os.ReadFile(path)
-			if readErr != nil {
-				fmt.Fprintf(os.Stderr, "Warning: Error reading file %s: %v\n", path, readErr)
-				return nil // Skip file if unreadable, but continue walk
+	// Cache the raw response, but display and export the post-processed one
+	displayChoices := responseChoices
+	if bufferOutput && (len(responseChoices) > 0 || codeFormat == formatJSON) {
+		if postProcessCmd != "" && len(responseChoices) > 0 {
+			displayChoices = postProcessChoices(responseChoices)
+		}
+		model := llmModel
+		if servedBy != "" {
+			model = servedBy
+		}
+		if err := showCodeResponse(displayChoices, model, false, pageResponse); err != nil {
+			return err
+		}
+	}
+
+	if !noHeader {
+		fmt.Println("--------------------") // Final separator on Stdout
+	}
+
+	if len(fallbackModels) > 0 && servedBy != "" {
+		logf("Served by: %s\n", servedBy)
+	}
+
+	if htmlOutputFile != "" && len(responseChoices) > 0 {
+		if err := writeHTMLExport(htmlOutputFile, "vibe: "+userPrompt, formatChoices(displayChoices)); err != nil {
+			return err
+		}
+	}
+
+	// Structured output must match the schema before it is cached or applied
+	if schema != nil {
+		for i, c := range responseChoices {
+			if err := schema.validate(c); err != nil {
+				if len(responseChoices) > 1 {
+					return fmt.Errorf("completion %d: %w", i+1, err)
+				}
+				return err
 			}
+		}
+	}
 
-			// Add file header and content to context
-			contextBuilder.WriteString(fmt.Sprintf("// File: %s\n", absPath))
-			contextBuilder.Write(content)
-			contextBuilder.WriteString("\n\n---\n\n") // Separator
-			filesCollected++
-			return nil
-		})
+	if useCache && cacheable && len(responseChoices) > 0 {
+		entry := responseCacheEntry{Model: llmModel, CreatedAt: time.Now(), Choices: responseChoices}
+		if err := saveCachedResponse(cacheKey, entry); err != nil {
+			warnf("cache", "Failed to cache response: %v\n", err)
+		}
+	}
 
-		if err != nil {
-			// This error is from WalkDir itself (e.g., initial permission error)
-			return fmt.Errorf("error walking the path %q: %w", absTargetDir, err)
+	if len(responseChoices) > 0 {
+		model := llmModel
+		if servedBy != "" {
+			model = servedBy
+		}
+		recordHistory(historyEntry{Command: "code", Model: model, Prompt: userPrompt, Files: len(files),
+			PromptTokens: tokenUsage.PromptTokens, CompletionTokens: tokenUsage.CompletionTokens,
+			Response: formatChoices(displayChoices)}, len(systemContent)+len(userContent))
+	}
+
+	if applyChanges && len(displayChoices) > 0 {
+		if err := applyResponse(absTargetDir, displayChoices[0]); err != nil {
+			return err
 		}
+	}
 
-		if filesCollected == 0 {
-			fmt.Fprintln(os.Stderr, "Warning: No relevant files found for context in the target directory.")
-			// Proceeding without file context
+	return nil // Success
+}
+
+// clipboardContext returns the clipboard contents as the single context entry
+// for --from-clipboard.
+func clipboardContext() ([]gatheredFile, error) {
+	content, err := readClipboard(isRunningUnderWSL())
+	if err != nil {
+		return nil, fmt.Errorf("--from-clipboard: failed to read the clipboard: %w", err)
+	}
+	if strings.TrimSpace(content) == "" {
+		return nil, fmt.Errorf("--from-clipboard: the clipboard is empty")
+	}
+	return []gatheredFile{{path: "clipboard", content: []byte(content)}}, nil
+}
+
+// resolveCodeEndpoint returns the endpoint code talks to: OpenRouter, or the
+// Azure deployment with --azure (which then also becomes llmModel).
+func resolveCodeEndpoint(cmd *cobra.Command) (chatEndpoint, error) {
+	if !useAzure {
+		apiKey := os.Getenv(apiKeyEnvVar)
+		if apiKey == "" {
+			return chatEndpoint{}, fmt.Errorf("API key not found. Please set the %s environment variable", apiKeyEnvVar)
+		}
+		return openRouterEndpoint(apiKey), nil
+	}
+	azure, err := resolveAzureConfig()
+	if err != nil {
+		return chatEndpoint{}, err
+	}
+	for _, name := range openRouterOnlyFlags {
+		if cmd.Flags().Changed(name) {
+			return chatEndpoint{}, fmt.Errorf("--%s is an OpenRouter option and can't be used with --azure", name)
+		}
+	}
+	llmModel = azure.deployment // Azure routes by deployment; also keeps cache keys apart
+	return azure.chatEndpoint(), nil
+}
+
+// chatEndpoint is a chat completions API that code sends requests to.
+type chatEndpoint struct {
+	name    string            // Shown in progress and error messages
+	url     string            // Chat completions URL
+	headers map[string]string // Authentication and attribution headers
+	keyEnv  string            // Environment variable the API key came from
+}
+
+// openRouterEndpoint returns the default OpenRouter endpoint, authenticated with apiKey.
+// Usage is attributed to vibe unless --app-referer or --app-title say otherwise.
+func openRouterEndpoint(apiKey string) chatEndpoint {
+	referer, title := projectURL, version.UserAgent()
+	if appReferer != "" {
+		referer = appReferer
+	}
+	if appTitle != "" {
+		title = appTitle
+	}
+	return chatEndpoint{name: "OpenRouter", url: openRouterAPIURL, headers: map[string]string{
+		"Authorization": "Bearer " + apiKey,
+		"HTTP-Referer":  referer, // Optional but recommended
+		"X-Title":       title,   // Optional but recommended
+	}, keyEnv: apiKeyEnvVar}
+}
+
+// saveRequest writes payload to --save-request, if set, as the indented JSON
+// body that postChatCompletion sends.
+func saveRequest(payload map[string]interface{}) error {
+	if saveRequestFile == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(payload, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal request for --save-request: %w", err)
+	}
+	if err := os.WriteFile(saveRequestFile, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write --save-request file: %w", err)
+	}
+	verbosef("Saved request body to %s\n", saveRequestFile)
+	return nil
+}
+
+// postChatCompletion sends payload to endpoint and returns the response if it
+// has a 200 status. Cancelling ctx aborts the request.
+func postChatCompletion(ctx context.Context, client *http.Client, endpoint chatEndpoint, payload map[string]interface{}) (*http.Response, error) {
+	requestBodyBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal final request payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint.url, bytes.NewBuffer(requestBodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+
+	// Set Headers
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range endpoint.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request to %s: %w", endpoint.name, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		var apiErrResp openRouterResponse
+		json.Unmarshal(bodyBytes, &apiErrResp) // Ignore unmarshal error here
+		errMsg := ""
+		if apiErrResp.Error.Message != "" {
+			errMsg = fmt.Sprintf("API Error: Type=%s, Message=%s", apiErrResp.Error.Type, apiErrResp.Error.Message)
 		} else {
-			fmt.Fprintf(os.Stderr, "Collected context from %d file(s). (Skipped %d directories)\n", filesCollected, skippedDirs)
+			errMsg = fmt.Sprintf("Body: %s", string(bodyBytes)) // Fallback to raw body
 		}
+		return nil, &apiStatusError{endpoint: endpoint.name, keyEnv: endpoint.keyEnv, statusCode: resp.StatusCode, status: resp.Status, detail: errMsg, message: apiErrResp.Error.Message}
+	}
+	return resp, nil
+}
 
-		// --- 4. Construct LLM Prompt ---
-		// System prompt explaining the task
-		systemContent := fmt.Sprintf(`You are an expert programming assistant integrated into a CLI tool called 'vibe'.
-The user is working in the project context provided below (code files from their directory).
-Analyze the user's request and the provided file context carefully.
-Generate the necessary code modifications, additions, or provide explanations as requested.
-Format your response clearly using Markdown. Use language-specific code blocks (e.g., `+"```"+`go ... `+"```"+`, `+"```"+`python ... `+"```"+`).
-If modifying existing code, clearly indicate the file and the changes. If adding new code, suggest where it should go.
-Focus on fulfilling the user's request accurately based *only* on the provided context and general programming best practices for the relevant language(s).
-Do not add extraneous conversation or introductory/concluding remarks outside of the requested code/explanation.
+// apiStatusError is a non-200 reply from a chat completions endpoint.
+type apiStatusError struct {
+	endpoint   string
+	keyEnv     string // The endpoint's API key variable, named when the key is rejected
+	statusCode int
+	status     string
+	detail     string // The API's error message, or the raw body
+	message    string // Just the API's error message, if it sent one
+}
 
---- FILE CONTEXT START ---
-%s
---- FILE CONTEXT END ---`, contextBuilder.String())
+func (e *apiStatusError) Error() string {
+	if isAuthStatus(e.statusCode) && e.keyEnv != "" {
+		return invalidKeyError(e.endpoint, e.keyEnv, e.statusCode, e.message).Error()
+	}
+	return fmt.Sprintf("received non-OK status code from %s: %d - %s. %s", e.endpoint, e.statusCode, e.status, e.detail)
+}
 
-		// User prompt combining context preamble and the actual request
-		userContent := fmt.Sprintf(`Based on the file context provided in the system message, fulfill the following request:
+// isRetryableError reports whether a failed request might succeed elsewhere:
+// rate limits, server errors such as an overloaded upstream (502/503), and
+// network failures.
+func isRetryableError(err error) bool {
+	var statusErr *apiStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.statusCode == http.StatusTooManyRequests || statusErr.statusCode >= 500
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
 
-"%s"`, userPrompt)
+// warnTruncated reports that a response stopped at the token limit. choice is
+// its 1-based number with --count, or 0 for a single response.
+func warnTruncated(choice int) {
+	which := "The response"
+	if choice > 0 && completionCount > 1 {
+		which = fmt.Sprintf("Response %d", choice)
+	}
+	warnf("truncated", "%s was truncated because it hit the token limit; raise --max-tokens for a longer answer.\n", which)
+}
 
-		// --- 5. Make API Call ---
-		// Use the determined streamOutput value here
-		fmt.Fprintf(os.Stderr, "Sending request to OpenRouter model: %s (Streaming: %v)...\n", llmModel, streamOutput)
+// streamResult describes a single streamed response read by readStream.
+type streamResult struct {
+	content  string
+	finished bool   // The model signalled the end ([DONE] or a finish_reason)
+	reason   string // The finish_reason, e.g. "stop" or "length"
+	model    string // The model that served the request, as reported in the chunks
+	stalled  bool   // The idle timer aborted the stream
+	errored  bool   // Chunks failed to decode or the API reported an error mid-stream
+	err      error  // The error chunk the API sent, which ends the stream
+}
+
+// rawStream receives a copy of every SSE line read, for --stream-raw.
+var rawStream io.Writer
+
+// openRawStream points rawStream at the --stream-raw destination and returns
+// a func that closes it.
+func openRawStream() (func(), error) {
+	if streamRawDest == "" {
+		return func() {}, nil
+	}
+	if streamRawDest == "-" {
+		rawStream = os.Stderr
+		return func() { rawStream = nil }, nil
+	}
+	f, err := os.Create(streamRawDest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open --stream-raw file: %w", err)
+	}
+	rawStream = f
+	return func() {
+		f.Close()
+		rawStream = nil
+	}, nil
+}
+
+// readStream reads OpenRouter's server-sent events from body, printing each
+// delta as it arrives unless buffer is set, or to preview if there is one
+// (which leaves out reasoning traces, so they can't upset its row count).
+// cancel is called to abort the request if no data arrives for
+// --stream-idle-timeout.
+func readStream(body io.Reader, cancel context.CancelFunc, buffer bool, preview *streamPreview) streamResult {
+	var onDelta func(string)
+	switch {
+	case preview != nil:
+		onDelta = preview.write
+	case !buffer:
+		onDelta = func(delta string) { fmt.Print(delta) } // Print raw delta to stdout immediately
+	}
+	return decodeStream(body, cancel, onDelta, preview == nil)
+}
 
-		requestPayload := openRouterRequest{
-			Model: llmModel,
-			Messages: []message{
-				{Role: "system", Content: systemContent},
-				{Role: "user", Content: userContent},
-			},
+// decodeStream does the work of readStream, passing each content delta to
+// onDelta (if not nil) and, with showReasoning, printing reasoning traces
+// dimmed on stderr.
+func decodeStream(body io.Reader, cancel context.CancelFunc, onDelta func(string), showReasoning bool) streamResult {
+	var result streamResult
+	var streamed strings.Builder
+	scanner := bufio.NewScanner(body)
+	inReasoning := false
+
+	// Abort if no data arrives for --stream-idle-timeout
+	var stalled atomic.Bool
+	var idleTimer *time.Timer
+	if streamIdleTimeout > 0 {
+		idleTimer = time.AfterFunc(streamIdleTimeout, func() {
+			stalled.Store(true)
+			cancel()
+		})
+		defer idleTimer.Stop()
+	}
+	for scanner.Scan() {
+		if idleTimer != nil {
+			idleTimer.Reset(streamIdleTimeout)
+		}
+		line := scanner.Text()
+		if line == "" {
+			continue // Skip empty lines
+		}
+		if rawStream != nil {
+			fmt.Fprintln(rawStream, line)
 		}
 
-		// Marshal base payload first
-		payloadBytes, err := json.Marshal(requestPayload)
+		if strings.HasPrefix(line, "data: ") {
+			data := strings.TrimPrefix(line, "data: ")
+			if data == "[DONE]" {
+				result.finished = true
+				break // End of stream
+			}
+
+			var chunk openRouterStreamResponse
+			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+				warnf("decode", "\nFailed to decode stream chunk: %v\nData: %s\n", err, data)
+				result.errored = true
+				continue
+			}
+
+			if chunk.Error.Message != "" {
+				// The API has given up on this request; nothing useful follows
+				result.err = fmt.Errorf("API error during stream: Type=%s, Message=%s", chunk.Error.Type, chunk.Error.Message)
+				result.errored = true
+				break
+			}
+
+			if chunk.Model != "" {
+				result.model = chunk.Model
+			}
+			if len(chunk.Choices) > 0 {
+				// Show the reasoning trace dimmed on stderr, ending it when the answer starts
+				if r := chunk.Choices[0].Delta.Reasoning; r != "" && showReasoning {
+					logf("%s", dimText(r))
+					inReasoning = true
+				}
+				contentDelta := chunk.Choices[0].Delta.Content
+				if contentDelta != "" && inReasoning {
+					logln()
+					inReasoning = false
+				}
+				if onDelta != nil && contentDelta != "" {
+					onDelta(contentDelta)
+				}
+				streamed.WriteString(contentDelta)
+				if fr := chunk.Choices[0].FinishReason; fr != nil && *fr != "" {
+					result.finished = true
+					result.reason = *fr
+				}
+			}
+		} // End if "data: "
+	} // End scanner loop
+
+	result.content = streamed.String()
+	if stalled.Load() {
+		result.stalled = true
+		return result
+	}
+	if err := scanner.Err(); err != nil {
+		// Leaves finished unset, which is what --resume recovers from
+		fmt.Fprintf(os.Stderr, "\nError reading stream: %v\n", err)
+	}
+	return result
+}
+
+// continuationMessages extends the original conversation with the partial
+// answer received so far and asks the model to carry on from where it stopped.
+func continuationMessages(messages []message, partial string) []message {
+	continued := append([]message{}, messages...)
+	return append(continued,
+		message{Role: "assistant", Content: partial},
+		message{Role: "user", Content: "Your previous answer was cut off. Continue exactly where it stopped, without repeating anything you already wrote."},
+	)
+}
+
+// codeSkipDirs lists directories code never gathers context from
+var codeSkipDirs = map[string]bool{
+	".git":         true,
+	"node_modules": true,
+	"vendor":       true,
+	"__pycache__":  true,
+	"venv":         true,
+	".venv":        true,
+	"target":       true, // Common for Rust/Java
+	"build":        true, // Common build output dir
+	"dist":         true, // Bundled JS/TS output
+	"coverage":     true, // Test coverage reports
+}
+
+// codeExtensionsToInclude lists the extensions (or exact lowercase names) code gathers
+var codeExtensionsToInclude = map[string]bool{
+	".go":           true,
+	".html":         true,
+	".py":           true,
+	".js":           true,
+	".ts":           true,
+	".jsx":          true,
+	".tsx":          true,
+	".rs":           true,
+	".java":         true,
+	".kt":           true,
+	".c":            true,
+	".h":            true,
+	".cpp":          true,
+	".cs":           true,
+	".rb":           true,
+	".php":          true,
+	".md":           true,
+	".yaml":         true,
+	".yml":          true,
+	".toml":         true,
+	".json":         true,
+	"dockerfile":    true, // Match Dockerfile exactly
+	".dockerignore": true,
+	".sh":           true,
+	".sql":          true,
+	".env":          true, ".env.example": true,
+}
+
+// gatherCodeContext collects the files code sends as context from
+// absTargetDir: nothing with --no-context, the clipboard with
+// --from-clipboard, the git diff with --context-from-git-diff, or else the
+// walk (with any --dir roots) narrowed to lineRanges. The preprocessing
+// flags, --readme-first and --token-budget are then applied, using
+// requestText to pick the files that matter. targetGiven reports whether the
+// directory was passed explicitly rather than defaulted.
+func gatherCodeContext(absTargetDir string, targetGiven bool, requestText string, lineRanges map[string][]lineRange, endpoint chatEndpoint) ([]gatheredFile, error) {
+	var files []gatheredFile
+	var err error
+	if noContext {
+		verbosef("Not gathering any context (--no-context)\n")
+	} else if fromClipboard {
+		logln("Reading context from the clipboard...")
+		files, err = clipboardContext()
 		if err != nil {
-			return fmt.Errorf("failed to marshal base request payload: %w", err)
+			return nil, err
 		}
-
-		// Use a map to easily add the 'stream' field conditionally
-		finalPayloadMap := map[string]interface{}{}
-		if err := json.Unmarshal(payloadBytes, &finalPayloadMap); err != nil {
-			return fmt.Errorf("failed to unmarshal payload to map: %w", err)
+	} else if contextFromDiff {
+		files, err = gitDiffContext(absTargetDir)
+		if err != nil {
+			return nil, err
 		}
-		// Add stream field based on the streamOutput variable
-		if streamOutput {
-			finalPayloadMap["stream"] = true
-		} // No need for 'else', default is false / field absent
-
-		// Marshal the final map containing the stream field if needed
-		requestBodyBytes, err := json.Marshal(finalPayloadMap)
+	} else {
+		opts, err := codeRootOptions(absTargetDir, targetGiven)
 		if err != nil {
-			return fmt.Errorf("failed to marshal final request payload: %w", err)
+			return nil, err
 		}
-
-		req, err := http.NewRequest("POST", openRouterAPIURL, bytes.NewBuffer(requestBodyBytes))
+		logf("Gathering context from: %s\n", strings.Join(append([]string{opts.root}, opts.extraRoots...), ", ")) // Use Stderr for progress
+		var stats walkStats
+		files, stats, err = gatherFiles(opts)
 		if err != nil {
-			return fmt.Errorf("failed to create HTTP request: %w", err)
+			// This error is from WalkDir itself (e.g., initial permission error)
+			return nil, fmt.Errorf("error walking the path %q: %w", opts.root, err)
 		}
 
-		// Set Headers
-		req.Header.Set("Authorization", "Bearer "+apiKey)
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("HTTP-Referer", projectURL) // Optional but recommended
-		req.Header.Set("X-Title", commandVersion)  // Optional but recommended
+		files = applyLineRanges(files, absTargetDir, lineRanges)
+		filesCollected := len(files)
 
-		client := &http.Client{Timeout: 180 * time.Second} // Reasonable timeout
-		resp, err := client.Do(req)
-		if err != nil {
-			return fmt.Errorf("failed to send request to OpenRouter: %w", err)
+		if filesCollected == 0 {
+			warnf("no-files", "No relevant files found for context in the target directory.\n")
+			// Proceeding without file context
+		} else {
+			logf("Collected context from %d file(s). (Skipped %d directories)\n", filesCollected, stats.skippedDirs)
 		}
-		defer resp.Body.Close()
+	}
+	if stripComments {
+		warnf("strip-comments", "--strip-comments changes what the model sees; it won't know about the removed comments.\n")
+		files = stripContextComments(files)
+	}
+	if stripBlankLines || collapseWhitespace {
+		files = normalizeWhitespace(files)
+	}
+	if orderByRelevance {
+		sortByRelevance(files, requestText)
+	}
+	if readmeFirst && !noContext && !fromClipboard && !contextFromDiff {
+		files = moveReadmeFirst(files, absTargetDir)
+	}
+	return fitTokenBudget(files, requestText, endpoint), nil
+}
 
-		// --- 6. Process Response ---
-		if resp.StatusCode != http.StatusOK {
-			bodyBytes, _ := io.ReadAll(resp.Body)
-			var apiErrResp openRouterResponse
-			json.Unmarshal(bodyBytes, &apiErrResp) // Ignore unmarshal error here
-			errMsg := ""
-			if apiErrResp.Error.Message != "" {
-				errMsg = fmt.Sprintf("API Error: Type=%s, Message=%s", apiErrResp.Error.Type, apiErrResp.Error.Message)
-			} else {
-				errMsg = fmt.Sprintf("Body: %s", string(bodyBytes)) // Fallback to raw body
-			}
-			return fmt.Errorf("received non-OK status code from OpenRouter: %d - %s. %s", resp.StatusCode, resp.Status, errMsg)
-		}
+// codeWalkOptions returns the walker configuration code uses to gather context from root.
+func codeWalkOptions(root string) walkOptions {
+	return walkOptions{
+		root:        root,
+		maxFileSize: 5 * 1024 * 1024, // Avoid reading excessively large files (e.g., > 5MB)
+		skipEmpty:   true,
+		dedup:       true,
+		normalize:   true,
+		skipDir:     codeSkipDir,
+		includeFile: codeIncludeFile,
+	}
+}
 
-		// --- 7. Display Result ---
-		fmt.Println("\n--- LLM Response ---") // Print header to Stdout
-		if streamOutput {
-			// == Streaming Logic ==
-			scanner := bufio.NewScanner(resp.Body)
-			streamErrorOccurred := false
-			for scanner.Scan() {
-				line := scanner.Text()
-				if line == "" {
-					continue // Skip empty lines
-				}
+// codeRootOptions returns the walk options for the directories code gathers
+// from: the target, then each --dir. Without a target argument, --dir
+// replaces the current directory rather than adding to it.
+func codeRootOptions(absTargetDir string, targetGiven bool) (walkOptions, error) {
+	var roots []string
+	if targetGiven || len(extraDirs) == 0 {
+		roots = append(roots, absTargetDir)
+	}
+	for _, dir := range extraDirs {
+		absDir, err := resolveTargetDir(dir)
+		if err != nil {
+			return walkOptions{}, fmt.Errorf("--dir: %w", err)
+		}
+		if !slices.Contains(roots, absDir) {
+			roots = append(roots, absDir)
+		}
+	}
+	opts := codeWalkOptions(roots[0])
+	opts.extraRoots = roots[1:]
+	return opts, nil
+}
 
-				if strings.HasPrefix(line, "data: ") {
-					data := strings.TrimPrefix(line, "data: ")
-					if data == "[DONE]" {
-						break // End of stream
-					}
-
-					var chunk openRouterStreamResponse
-					if err := json.Unmarshal([]byte(data), &chunk); err != nil {
-						fmt.Fprintf(os.Stderr, "\nWarning: Failed to decode stream chunk: %v\nData: %s\n", err, data)
-						streamErrorOccurred = true
-						continue
-					}
-
-					if chunk.Error.Message != "" {
-						fmt.Fprintf(os.Stderr, "\nAPI Error during stream: Type=%s, Message=%s\n", chunk.Error.Type, chunk.Error.Message)
-						streamErrorOccurred = true
-						continue // Or break
-					}
-
-					if len(chunk.Choices) > 0 {
-						contentDelta := chunk.Choices[0].Delta.Content
-						fmt.Print(contentDelta) // Print raw delta to stdout immediately
-					}
-				} // End if "data: "
-			} // End scanner loop
-
-			if err := scanner.Err(); err != nil {
-				fmt.Fprintf(os.Stderr, "\nError reading stream: %v\n", err)
-				streamErrorOccurred = true
-			}
-			fmt.Println() // Add a newline after streaming is done / before rendering
+// codeSkipDir reports whether code should skip a directory.
+func codeSkipDir(dirName string) bool {
+	return codeSkipDirs[dirName] || strings.HasPrefix(dirName, ".")
+}
 
-			if streamErrorOccurred {
-				fmt.Fprintln(os.Stderr, "Note: Errors occurred during streaming. Output may be incomplete.")
-			}
+// codeIncludeFile reports whether code should include a file in the context.
+func codeIncludeFile(fileName string) bool {
+	// Skip hidden files (allow specific dotfiles like .env)
+	if strings.HasPrefix(fileName, ".") && !codeExtensionsToInclude[fileName] {
+		return false
+	}
+
+	// Skip minified bundles, which are build artifacts
+	fileNameLower := strings.ToLower(fileName)
+	if strings.HasSuffix(fileNameLower, ".min.js") || strings.HasSuffix(fileNameLower, ".min.css") {
+		return false
+	}
+
+	// Include files based on extension map or exact name matches
+	fileExtLower := strings.ToLower(filepath.Ext(fileNameLower))
+	return codeExtensionsToInclude[fileExtLower] || codeExtensionsToInclude[fileNameLower]
+}
 
-		} else {
-			// == Non-Streaming Logic ==
-			var openRouterResp openRouterResponse
-			bodyBytes, readErr := io.ReadAll(resp.Body)
-			if readErr != nil {
-				return fmt.Errorf("failed to read non-streaming response body: %w", readErr)
+// buildProviderPreferences builds OpenRouter's "provider" request object from the
+// --provider-order, --allow-fallbacks and --data-collection flags. It returns nil if neither was set.
+// See https://openrouter.ai/docs/features/provider-routing for accepted provider names.
+func buildProviderPreferences(cmd *cobra.Command) (map[string]interface{}, error) {
+	prefs := map[string]interface{}{}
+	if cmd.Flags().Changed("provider-order") {
+		if len(providerOrder) == 0 {
+			return nil, fmt.Errorf("--provider-order requires at least one provider name")
+		}
+		for _, p := range providerOrder {
+			if strings.TrimSpace(p) == "" {
+				return nil, fmt.Errorf("--provider-order contains an empty provider name")
 			}
+		}
+		prefs["order"] = providerOrder
+	}
+	if cmd.Flags().Changed("allow-fallbacks") {
+		prefs["allow_fallbacks"] = allowFallbacks
+	}
+	if dataCollection != "" {
+		if dataCollection != "allow" && dataCollection != "deny" {
+			return nil, fmt.Errorf("invalid --data-collection %q: must be 'allow' or 'deny'", dataCollection)
+		}
+		prefs["data_collection"] = dataCollection
+	}
+	if len(prefs) == 0 {
+		return nil, nil
+	}
+	return prefs, nil
+}
 
-			if err := json.Unmarshal(bodyBytes, &openRouterResp); err != nil {
-				return fmt.Errorf("failed to decode non-streaming OpenRouter response: %w. Body: %s", err, string(bodyBytes))
-			}
+// withAppendedSystem adds the --append-system text, if any, to the end of the
+// system prompt.
+func withAppendedSystem(systemContent string) (string, error) {
+	if appendSystem == "" {
+		return systemContent, nil
+	}
+	extra, err := loadTextArg(appendSystem)
+	if err != nil {
+		return "", fmt.Errorf("--append-system: %w", err)
+	}
+	return systemContent + "\n\n" + extra, nil
+}
 
-			if openRouterResp.Error.Message != "" {
-				return fmt.Errorf("received API error: Type=%s, Message=%s", openRouterResp.Error.Type, openRouterResp.Error.Message)
-			}
+// checkPromptNotDirectory catches the prompt argument being a directory, as
+// in "vibe code ./src" or "vibe code ./src 'fix the bug'", which would
+// otherwise send the path as the request. A prompt that merely names a
+// directory alongside a real target directory is left alone.
+func checkPromptNotDirectory(args []string) error {
+	if editPrompt || len(args) == 0 {
+		return nil
+	}
+	if info, err := os.Stat(args[0]); err != nil || !info.IsDir() {
+		return nil
+	}
+	if len(args) == 1 {
+		return fmt.Errorf("%q is a directory, not a prompt; code takes the prompt first: vibe code \"<prompt>\" %s\n(use vibe show %s to print its context without asking the model)", args[0], args[0], args[0])
+	}
+	if _, err := os.Stat(args[1]); os.IsNotExist(err) {
+		return fmt.Errorf("%q is a directory, not a prompt; the prompt comes first: vibe code %q %s", args[0], args[1], args[0])
+	}
+	return nil
+}
 
-			if len(openRouterResp.Choices) == 0 || openRouterResp.Choices[0].Message.Content == "" {
-				fmt.Fprintln(os.Stderr, "Warning: Received an empty non-streaming response from the LLM.")
-			} else {
-				content := openRouterResp.Choices[0].Message.Content
-				fmt.Println(content) // Print raw content directly
-			}
+// wrapUserPrompt surrounds the request with --prompt-prefix and --prompt-suffix,
+// falling back to the config's prompt_prefix and prompt_suffix when a flag isn't given.
+func wrapUserPrompt(cmd *cobra.Command, userPrompt string) (string, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return "", err
+	}
+	prefix, suffix := cfg.PromptPrefix, cfg.PromptSuffix
+	if cmd.Flags().Changed("prompt-prefix") {
+		prefix = promptPrefix
+	}
+	if cmd.Flags().Changed("prompt-suffix") {
+		suffix = promptSuffix
+	}
+
+	if prefix, err = loadTextArg(prefix); err != nil {
+		return "", fmt.Errorf("--prompt-prefix: %w", err)
+	}
+	if suffix, err = loadTextArg(suffix); err != nil {
+		return "", fmt.Errorf("--prompt-suffix: %w", err)
+	}
+
+	parts := []string{}
+	for _, text := range []string{prefix, userPrompt, suffix} {
+		if text != "" {
+			parts = append(parts, text)
 		}
+	}
+	return strings.Join(parts, "\n\n"), nil
+}
 
-		fmt.Println("--------------------") // Final separator on Stdout
+// postProcessChoices runs each completion through the --post-process command.
+func postProcessChoices(choices []string) []string {
+	processed := make([]string, len(choices))
+	for i, c := range choices {
+		processed[i] = postProcessResponse(postProcessCmd, postProcessScope, c)
+	}
+	return processed
+}
 
-		return nil // Success
-	},
+// formatChoices returns a single completion as-is, or labels each of several
+// completions (--count) with a numbered heading.
+func formatChoices(choices []string) string {
+	if len(choices) == 1 {
+		return choices[0]
+	}
+	var b strings.Builder
+	for i, c := range choices {
+		fmt.Fprintf(&b, "\n### Completion %d of %d\n\n%s\n", i+1, len(choices), c)
+	}
+	return b.String()
 }
 
 // --- Init Function ---
@@ -437,6 +1569,90 @@ func init() {
 
 	// Define flags for the code command
 	codeCmd.Flags().StringVarP(&llmModel, "model", "m", defaultModel, "LLM model to use via OpenRouter")
+	codeCmd.Flags().Var(&maxCost, "max-cost", "Ask before sending a request estimated to cost more than this, e.g. '$0.50' (default from config max_cost)")
+	codeCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Send requests over --max-cost without asking")
+	codeCmd.Flags().StringVar(&manifestPath, "manifest", "", "Write a JSON manifest (path, size, sha256) of the files sent as context to this file")
+	codeCmd.Flags().StringVar(&contextReportDest, "context-report", "", "List each context file's estimated tokens, largest first, on stderr (or =FILE to write them to a file)")
+	codeCmd.Flags().Lookup("context-report").NoOptDefVal = "-"
+	codeCmd.Flags().StringVar(&streamRawDest, "stream-raw", "", "Copy every raw line of the response stream to stderr, or to FILE with --stream-raw=FILE")
+	codeCmd.Flags().Lookup("stream-raw").NoOptDefVal = "-"
+	codeCmd.Flags().StringVar(&saveRequestFile, "save-request", "", "Write the exact JSON request body (pretty-printed, without headers) to this file before sending")
+	codeCmd.Flags().StringSliceVar(&compareModels, "compare", nil, "Comma-separated models (or aliases) to stream side by side on the same context and prompt, instead of --model")
+	codeCmd.Flags().StringSliceVar(&fallbackModels, "models", nil, "Comma-separated models (or aliases) OpenRouter tries in order if --model is unavailable")
+	codeCmd.Flags().StringVar(&fallbackModel, "fallback-model", "", "Model (or alias) to retry with once if the request fails with a rate limit, server or network error")
 	// Flag to DISABLE streaming (default is now streaming)
-	codeCmd.Flags().BoolVar(&noStream, "no-stream", false, "Disable streaming output (stream is default)")
+	codeCmd.Flags().BoolVar(&noStream, "no-stream", false, "Request the whole response at once instead of streaming it; it is still printed in the --format chosen")
+	codeCmd.Flags().BoolVar(&noHeader, "no-header", false, "Suppress the '--- LLM Response ---' banners so stdout contains only the model's content")
+	codeCmd.Flags().DurationVar(&streamIdleTimeout, "stream-idle-timeout", 60*time.Second, "Abort streaming if no data arrives for this long (0 disables)")
+	codeCmd.Flags().BoolVar(&resumeStream, "resume", false, fmt.Sprintf("If the stream drops before the model finishes, reconnect (up to %d times, with exponential backoff) and ask it to continue; costs extra tokens", maxResumeAttempts))
+	codeCmd.Flags().StringVar(&reasoningEffort, "reasoning-effort", "", "Reasoning effort for models that support it: low, medium or high (omitted unless set)")
+	codeCmd.Flags().IntVar(&maxTokens, "max-tokens", 0, "Maximum tokens the model may generate (0 = the model's default)")
+	codeCmd.Flags().IntVar(&completionCount, "count", 1, "Number of completions to request and display (requires --no-stream when > 1)")
+	codeCmd.Flags().StringSliceVar(&providerOrder, "provider-order", nil, "Comma-separated OpenRouter upstream providers to try in order (e.g. Anthropic,Amazon Bedrock)")
+	codeCmd.Flags().BoolVar(&allowFallbacks, "allow-fallbacks", true, "Allow OpenRouter to fall back to providers not in --provider-order")
+	codeCmd.Flags().StringVar(&dataCollection, "data-collection", "", "OpenRouter provider data policy: 'deny' to only use providers that don't store or train on prompts, or 'allow'")
+	codeCmd.Flags().StringSliceVar(&transforms, "transforms", nil, "OpenRouter prompt transforms to apply, e.g. middle-out (pass --transforms= to send none)")
+	codeCmd.Flags().StringVar(&appReferer, "app-referer", "", "HTTP-Referer sent to OpenRouter for usage attribution (default "+projectURL+")")
+	codeCmd.Flags().StringVar(&appTitle, "app-title", "", "X-Title sent to OpenRouter for usage attribution (default vibe's user agent)")
+	codeCmd.Flags().BoolVar(&noCache, "no-cache", false, "Always query the model, ignoring and not updating the response cache")
+	codeCmd.Flags().DurationVar(&cacheTTL, "cache-ttl", time.Hour, "How long cached responses are reused (0 means forever)")
+	codeCmd.Flags().StringVar(&contextRole, "context-role", "system", "Message that carries the file context: 'system' or 'user' (for models that down-weight system prompts)")
+	codeCmd.Flags().StringVar(&contextStyle, "context-style", "markers", "How the file context is framed in the prompt: 'markers', 'xml' (<file path=\"...\"> entries) or 'none'")
+	codeCmd.Flags().StringVar(&contextSeparator, "context-separator", "---", "Line placed between files in the context (not used by --context-style xml)")
+	codeCmd.Flags().StringVar(&postProcessCmd, "post-process", "", "Shell command to pipe the response through before displaying it (e.g. gofmt)")
+	codeCmd.Flags().StringVar(&postProcessScope, "post-process-scope", "blocks", "What --post-process receives: 'blocks' (each fenced code block) or 'response' (the whole text)")
+	codeCmd.Flags().StringVar(&promptTemplate, "template", "", "Build the prompt from ~/.config/vibe/templates/<name>.tmpl (the prompt argument fills {{.Prompt}})")
+	codeCmd.Flags().StringVar(&promptPrefix, "prompt-prefix", "", "Text to put before the request, or @file to read it from a file (default from config prompt_prefix)")
+	codeCmd.Flags().StringVar(&promptSuffix, "prompt-suffix", "", "Text to put after the request, e.g. \"Respond with only the code.\", or @file (default from config prompt_suffix)")
+	codeCmd.Flags().StringVar(&appendSystem, "append-system", "", "Text to add to the end of the default system prompt (e.g. coding standards), or @file to read it from a file")
+	codeCmd.Flags().StringToStringVar(&templateVars, "var", nil, "Template variables as key=value pairs, available as {{.key}}")
+	codeCmd.Flags().StringVar(&jsonSchemaSpec, "json-schema", "", "Require a JSON response: 'edits' (built-in {file, content} edits for --apply) or =FILE for a custom schema")
+	codeCmd.Flags().Lookup("json-schema").NoOptDefVal = editsSchemaName
+	codeCmd.Flags().BoolVar(&usePager, "pager", false, "Show the rendered response through $PAGER (default less -R) when stdout is a terminal")
+	codeCmd.Flags().BoolVar(&stripComments, "strip-comments", false, "Remove comments from the file context (supported languages only) to save tokens")
+	codeCmd.Flags().BoolVar(&stripBlankLines, "strip-blank-lines", false, "Remove blank lines from the file context to save tokens")
+	codeCmd.Flags().BoolVar(&collapseWhitespace, "collapse-whitespace", false, "Collapse runs of blank lines in the file context into one")
+	codeCmd.Flags().BoolVar(&orderByRelevance, "context-order-by-relevance", false, "Put the files that mention the prompt's keywords most first (overrides --context-order)")
+	codeCmd.Flags().BoolVar(&readmeFirst, "readme-first", false, "Put the target's README.md at the top of the context, marked as the project overview")
+	codeCmd.Flags().StringVar(&preambleFile, "preamble-file", "", "File whose content (e.g. coding standards) is put at the start of the file context, before any files")
+	codeCmd.Flags().StringVar(&footerFile, "footer-file", "", "File whose content (e.g. the key instruction) is put at the end of the file context, after all files")
+	codeCmd.Flags().IntVar(&tokenBudget, "token-budget", 0, "Estimated token limit for the file context; the least relevant files are dropped to fit (0 = unlimited)")
+	codeCmd.Flags().BoolVar(&compressOverBudget, "compress-over-budget", false, "With --token-budget, summarize files in one line instead of dropping them")
+	codeCmd.Flags().Var(&langWeight, "lang-weight", "With --token-budget, per-extension weights such as go=1,ts=0.3; lower-weighted files are trimmed first")
+	codeCmd.Flags().StringVar(&compressModel, "compress-model", defaultCompressModel, "Model that writes the --compress-over-budget summaries")
+	codeCmd.Flags().BoolVar(&contextHighlight, "context-highlight", false, "List the focused files (--file or named in the prompt) and the number of supporting files on stderr")
+	codeCmd.Flags().BoolVar(&contextFromDiff, "context-from-git-diff", false, "Use git diff BASE...HEAD as the context instead of the whole directory")
+	codeCmd.Flags().StringVar(&diffBase, "base", "", "With --context-from-git-diff, the ref to diff from (default main or master)")
+	codeCmd.Flags().StringVar(&diffHead, "diff-head", "HEAD", "With --context-from-git-diff, the ref to diff to")
+	codeCmd.Flags().BoolVar(&diffWithFiles, "diff-with-files", false, "With --context-from-git-diff, also include the full content of the changed files")
+	codeCmd.Flags().BoolVar(&fromClipboard, "from-clipboard", false, "Use the clipboard contents as the context instead of gathering files")
+	codeCmd.MarkFlagsMutuallyExclusive("context-from-git-diff", "from-clipboard")
+	codeCmd.Flags().StringArrayVar(&contextLineRanges, "context-line-range", nil, "Only send these lines of a file, e.g. foo.go:100-160 (relative to the target directory); repeatable")
+	codeCmd.Flags().BoolVar(&noContext, "no-context", false, "Send only the prompt, without gathering any file context")
+	codeCmd.Flags().StringArrayVar(&extraDirs, "dir", nil, "Also gather context from this directory, labelling its files with it; repeatable (without a target argument, only the --dir directories are gathered)")
+	codeCmd.MarkFlagsMutuallyExclusive("no-context", "from-clipboard", "context-from-git-diff")
+	codeCmd.Flags().BoolVar(&applyChanges, "apply", false, "Write the file blocks in the response to disk (backing up originals to .vibe.bak)")
+	codeCmd.Flags().BoolVar(&onlyClean, "only-clean", false, "With --apply, skip files that have uncommitted git changes")
+	codeCmd.Flags().BoolVar(&forceApply, "force", false, "With --apply --only-clean, overwrite files even if they have uncommitted changes")
+	codeCmd.Flags().StringVar(&checkCommand, "check", "", "With --apply, run this command in the target directory afterwards and fail if it does (bare --check runs \""+defaultCheckCommand+"\")")
+	codeCmd.Flags().Lookup("check").NoOptDefVal = defaultCheckCommand
+	codeCmd.Flags().BoolVar(&checkRollback, "check-rollback", false, "With --check, restore the backed-up files (and remove new ones) when the check fails")
+	codeCmd.Flags().BoolVar(&interactiveMode, "interactive", false, "Chat about the context in a loop, reading prompts from stdin")
+	codeCmd.Flags().BoolVar(&watchMode, "watch", false, "Re-run the request whenever a file in the context changes")
+	codeCmd.Flags().DurationVar(&watchDebounce, "watch-debounce", 500*time.Millisecond, "With --watch, wait for changes to settle this long before re-running")
+	codeCmd.Flags().BoolVar(&watchClear, "watch-clear", false, "With --watch, clear the screen before each run instead of appending")
+	codeCmd.MarkFlagsMutuallyExclusive("no-context", "watch")
+	codeCmd.MarkFlagsMutuallyExclusive("no-context", "preamble-file")
+	codeCmd.MarkFlagsMutuallyExclusive("no-context", "footer-file")
+	codeCmd.MarkFlagsMutuallyExclusive("dir", "no-context", "from-clipboard", "context-from-git-diff")
+	codeCmd.MarkFlagsMutuallyExclusive("dir", "interactive", "watch")
+	codeCmd.Flags().BoolVar(&editPrompt, "edit", false, "Write the prompt in $EDITOR (the only argument is then the target directory)")
+	codeCmd.MarkFlagsMutuallyExclusive("edit", "interactive", "watch")
+	codeCmd.Flags().Var(&codeFormat, "format", "Output format: text (streamed), markdown (rendered once complete) or json")
+	codeCmd.Flags().BoolVar(&streamBufferRender, "stream-buffer-render", false, "Stream a dimmed preview of the raw response, then replace it with the rendered Markdown (terminals only)")
+	codeCmd.MarkFlagsMutuallyExclusive("format", "interactive")
+	codeCmd.Flags().StringVar(&htmlOutputFile, "html", "", "Also export the response as a standalone HTML file")
+	codeCmd.Flags().BoolVar(&noHistory, "no-history", false, "Leave this request out of the history log (see vibe history)")
+	addWalkFlags(codeCmd)
+	addAzureFlags(codeCmd)
 }
@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	streamErrorLine = `data: {"error":{"type":"server_error","message":"upstream overloaded"}}`
+	streamChunkLine = `data: {"choices":[{"delta":{"content":"after the error"}}]}`
+)
+
+func TestDecodeStreamStopsAtErrorChunk(t *testing.T) {
+	// Each line is a separate pipe write, which blocks until it is read, so the
+	// second write only succeeds if decodeStream reads past the error chunk.
+	pr, pw := io.Pipe()
+	chunkWritten := make(chan error, 1)
+	go func() {
+		if _, err := io.WriteString(pw, streamErrorLine+"\n"); err != nil {
+			chunkWritten <- err
+			return
+		}
+		_, err := io.WriteString(pw, streamChunkLine+"\n")
+		chunkWritten <- err
+		pw.Close()
+	}()
+
+	var deltas []string
+	result := decodeStream(pr, func() {}, func(d string) { deltas = append(deltas, d) }, false)
+	pr.Close() // Fails the pending write, unless the chunk was already read
+
+	if result.err == nil {
+		t.Fatal("result.err is nil, want the API error")
+	}
+	if !strings.Contains(result.err.Error(), "upstream overloaded") {
+		t.Errorf("result.err = %v, want the API's message", result.err)
+	}
+	if !result.errored {
+		t.Error("result.errored is false")
+	}
+	if err := <-chunkWritten; err == nil {
+		t.Error("the chunk after the error was read")
+	}
+	if len(deltas) > 0 || result.content != "" {
+		t.Errorf("got content %q (deltas %q), want none", result.content, deltas)
+	}
+}
+
+// resetFlags restores the named flags of cmd, set by a test, to their defaults.
+func resetFlags(t *testing.T, cmd *cobra.Command, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		f := cmd.Flags().Lookup(name)
+		var err error
+		if slice, ok := f.Value.(interface{ Replace([]string) error }); ok {
+			err = slice.Replace(nil) // Set would append to a slice flag
+		} else {
+			err = f.Value.Set(f.DefValue)
+		}
+		if err != nil {
+			t.Errorf("resetting --%s: %v", name, err)
+		}
+		f.Changed = false
+	}
+}
+
+func TestRunCodeFailsOnStreamError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "%s\n\n%s\n\ndata: [DONE]\n\n", streamErrorLine, streamChunkLine)
+	}))
+	defer server.Close()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", home+"/config")
+	t.Setenv("XDG_CACHE_HOME", home+"/cache")
+	t.Setenv("XDG_DATA_HOME", home+"/data")
+	t.Setenv(azureAPIKeyEnvVar, "test-key")
+	root := writeTree(t, map[string]string{"main.go": "package main\n"})
+
+	args := []string{"explain main.go", root}
+	err := codeCmd.ParseFlags([]string{
+		"--azure", "--azure-endpoint", server.URL, "--azure-deployment", "test",
+		"--no-cache", "--no-header", "--quiet",
+	})
+	t.Cleanup(func() {
+		resetFlags(t, codeCmd, "azure", "azure-endpoint", "azure-deployment", "no-cache", "no-header", "quiet", "model")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = runCode(codeCmd, args)
+	if err == nil {
+		t.Fatal("runCode returned nil, want the stream's API error")
+	}
+	if !strings.Contains(err.Error(), "upstream overloaded") {
+		t.Errorf("runCode error = %v, want the API's message", err)
+	}
+}
+
+// routeAPITo sends every HTTPS request made through newHTTPClient to server,
+// whatever its host, so that commands talk to it instead of OpenRouter.
+func routeAPITo(t *testing.T, server *httptest.Server) {
+	t.Helper()
+	for _, env := range []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy"} {
+		t.Setenv(env, "")
+	}
+	transport := http.DefaultTransport
+	t.Cleanup(func() { http.DefaultTransport = transport })
+	http.DefaultTransport = &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+		},
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, // The test server's certificate isn't for the API's host
+	}
+}
+
+func TestRunCodeCompareSkipsCache(t *testing.T) {
+	var requests, compared atomic.Int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		var payload struct {
+			Model string `json:"model"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err == nil && (payload.Model == "test/a" || payload.Model == "test/b") {
+			compared.Add(1)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"answer\"},\"finish_reason\":\"stop\"}]}\n\ndata: [DONE]\n\n")
+	}))
+	defer server.Close()
+	routeAPITo(t, server)
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", home+"/config")
+	t.Setenv("XDG_CACHE_HOME", home+"/cache")
+	t.Setenv("XDG_DATA_HOME", home+"/data")
+	t.Setenv(apiKeyEnvVar, "test-key")
+	root := writeTree(t, map[string]string{"main.go": "package main\n"})
+	args := []string{"explain main.go", root}
+	t.Cleanup(func() { resetFlags(t, codeCmd, "no-header", "quiet", "compare", "model") })
+
+	// The first request caches the single model's answer
+	if err := codeCmd.ParseFlags([]string{"--no-header", "--quiet"}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := runCode(codeCmd, args); err != nil {
+			t.Fatalf("runCode: %v", err)
+		}
+	}
+	if got := requests.Load(); got != 1 {
+		t.Fatalf("%d requests for two identical runs, want 1 (the second from the cache)", got)
+	}
+
+	if err := codeCmd.ParseFlags([]string{"--compare", "test/a,test/b"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := runCode(codeCmd, args); err != nil {
+		t.Fatalf("runCode --compare: %v", err)
+	}
+	if got := compared.Load(); got != 2 {
+		t.Errorf("--compare sent %d requests to the compared models, want 2 despite the cached answer", got)
+	}
+}
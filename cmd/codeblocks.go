@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"strings"
+)
+
+// codeBlock is a fenced code block found in a Markdown response.
+type codeBlock struct {
+	info      string // Everything after the opening fence, e.g. "go" or "go path/to/file.go"
+	body      string // The block content, without the fences
+	start     int    // Byte offset in the source where body starts
+	end       int    // Byte offset in the source where body ends
+	fenceLine int    // Index of the line holding the opening fence
+}
+
+// findCodeBlocks returns the fenced (```) code blocks in markdown, in order.
+// An unterminated block at the end of the text is ignored.
+func findCodeBlocks(markdown string) []codeBlock {
+	var blocks []codeBlock
+	var open *codeBlock
+	openFence := ""
+	offset := 0
+	for i, line := range strings.SplitAfter(markdown, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case open == nil && strings.HasPrefix(trimmed, "```"):
+			fence := leadingBackticks(trimmed)
+			open = &codeBlock{info: strings.TrimSpace(trimmed[len(fence):]), start: offset + len(line), fenceLine: i}
+			openFence = fence
+		case open != nil && strings.HasPrefix(trimmed, openFence) && strings.Trim(trimmed, "`") == "":
+			open.end = offset
+			open.body = markdown[open.start:open.end]
+			blocks = append(blocks, *open)
+			open = nil
+		}
+		offset += len(line)
+	}
+	return blocks
+}
+
+// leadingBackticks returns the run of backticks at the start of s.
+func leadingBackticks(s string) string {
+	n := 0
+	for n < len(s) && s[n] == '`' {
+		n++
+	}
+	return s[:n]
+}
+
+// lang returns the language tag of the block (the first word of its info string).
+func (b codeBlock) lang() string {
+	if fields := strings.Fields(b.info); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+)
+
+var stripComments bool // Flag to remove comments from the context
+
+// commentSyntax describes what stripCommentsFrom needs to know about a
+// language: its comment markers and the quotes that delimit its strings, so
+// comment-like text inside strings is left alone.
+type commentSyntax struct {
+	line       string // Line comment marker, e.g. "//"
+	blockStart string // Block comment markers, e.g. "/*" and "*/"
+	blockEnd   string
+	quotes     string // Characters that open and close strings
+	rawQuote   byte   // A quote with no escapes inside (Go's backtick), or 0
+	triple     bool   // Strings may be triple-quoted (Python)
+	hashRule   bool   // The line marker only counts at line start or after whitespace (shells, YAML)
+}
+
+var (
+	cLikeComments  = commentSyntax{line: "//", blockStart: "/*", blockEnd: "*/", quotes: `"'`}
+	tripleComments = commentSyntax{line: "//", blockStart: "/*", blockEnd: "*/", quotes: `"'`, triple: true}
+	hashComments   = commentSyntax{line: "#", quotes: `"'`, hashRule: true}
+)
+
+// commentSyntaxByExt lists the languages --strip-comments supports.
+var commentSyntaxByExt = map[string]commentSyntax{
+	".go":   {line: "//", blockStart: "/*", blockEnd: "*/", quotes: `"'`, rawQuote: '`'},
+	".js":   {line: "//", blockStart: "/*", blockEnd: "*/", quotes: "\"'`"},
+	".jsx":  {line: "//", blockStart: "/*", blockEnd: "*/", quotes: "\"'`"},
+	".ts":   {line: "//", blockStart: "/*", blockEnd: "*/", quotes: "\"'`"},
+	".tsx":  {line: "//", blockStart: "/*", blockEnd: "*/", quotes: "\"'`"},
+	".rs":   {line: "//", blockStart: "/*", blockEnd: "*/", quotes: `"`}, // ' also starts lifetimes
+	".java": cLikeComments, ".kt": tripleComments, ".scala": tripleComments, ".swift": tripleComments,
+	".c": cLikeComments, ".h": cLikeComments, ".cpp": cLikeComments, ".hpp": cLikeComments,
+	".cs": cLikeComments, ".dart": tripleComments, ".proto": cLikeComments,
+	".py": {line: "#", quotes: `"'`, triple: true},
+	".rb": hashComments, ".sh": hashComments, ".bash": hashComments, ".zsh": hashComments,
+	".yaml": hashComments, ".yml": hashComments,
+	".toml": {line: "#", quotes: `"'`, triple: true, hashRule: true},
+	".css":  {blockStart: "/*", blockEnd: "*/", quotes: `"'`},
+}
+
+// stripContextComments applies --strip-comments to the files whose language
+// is supported and reports the bytes saved.
+func stripContextComments(files []gatheredFile) []gatheredFile {
+	before, after := 0, 0
+	for i, f := range files {
+		syntax, ok := commentSyntaxByExt[strings.ToLower(filepath.Ext(f.path))]
+		if !ok {
+			continue
+		}
+		before += len(f.content)
+		files[i].content = stripCommentsFrom(f.content, syntax)
+		after += len(files[i].content)
+	}
+	if saved := before - after; saved > 0 {
+		logf("Comment stripping removed %d of %d bytes (~%d tokens).\n", saved, before, estimateTokens(saved))
+	}
+	return files
+}
+
+// commentStripper accumulates stripCommentsFrom's output a line at a time,
+// so lines a comment leaves empty can be dropped.
+type commentStripper struct {
+	out, line  bytes.Buffer
+	hadComment bool // Something was removed from the current line
+}
+
+// endLine flushes the current line: unchanged if nothing was removed from it,
+// right-trimmed if something was, and dropped if that leaves it empty.
+func (c *commentStripper) endLine() {
+	text := c.line.Bytes()
+	if c.hadComment {
+		ending := ""
+		if bytes.HasSuffix(text, []byte("\r\n")) {
+			ending = "\r\n"
+		} else if bytes.HasSuffix(text, []byte("\n")) {
+			ending = "\n"
+		}
+		text = bytes.TrimRight(text, " \t\r\n")
+		if len(bytes.TrimSpace(text)) > 0 {
+			c.out.Write(text)
+			c.out.WriteString(ending)
+		}
+	} else {
+		c.out.Write(text)
+	}
+	c.line.Reset()
+	c.hadComment = false
+}
+
+// write copies text into the current line, ending lines at its newlines.
+func (c *commentStripper) write(text []byte) {
+	for _, b := range text {
+		c.line.WriteByte(b)
+		if b == '\n' {
+			c.endLine()
+		}
+	}
+}
+
+// stripCommentsFrom removes the comments in content. Lines left empty by the
+// removal are dropped, and trailing whitespace a comment leaves behind is
+// trimmed; other lines are unchanged. A #! first line and Go directives
+// (//go:build and friends) are kept, since they change how the file is run
+// or built. Strings are skipped, honoring backslash escapes; one that isn't
+// closed by the end of the line (outside raw and triple-quoted strings) is
+// assumed to end there, so an odd quote can't hide the rest of the file.
+func stripCommentsFrom(content []byte, s commentSyntax) []byte {
+	var c commentStripper
+	c.out.Grow(len(content))
+	if bytes.HasPrefix(content, []byte("#!")) {
+		end := bytes.IndexByte(content, '\n') + 1
+		if end == 0 {
+			end = len(content)
+		}
+		c.out.Write(content[:end])
+		content = content[end:]
+	}
+
+	for i := 0; i < len(content); {
+		ch := content[i]
+		rest := content[i:]
+		switch {
+		case s.triple && (bytes.HasPrefix(rest, []byte(`"""`)) || bytes.HasPrefix(rest, []byte("'''"))):
+			n := len(rest)
+			if end := bytes.Index(rest[3:], rest[:3]); end >= 0 {
+				n = end + 6
+			}
+			c.write(rest[:n])
+			i += n
+		case strings.IndexByte(s.quotes, ch) >= 0 || (s.rawQuote != 0 && ch == s.rawQuote):
+			multiline := ch == s.rawQuote || ch == '`'
+			n := 1
+			for n < len(rest) && rest[n] != ch && (multiline || rest[n] != '\n') {
+				if rest[n] == '\\' && ch != s.rawQuote {
+					n++
+				}
+				n++
+			}
+			if n < len(rest) && rest[n] == ch {
+				n++
+			}
+			n = min(n, len(rest))
+			c.write(rest[:n])
+			i += n
+		case s.line != "" && bytes.HasPrefix(rest, []byte(s.line)) &&
+			!(s.line == "//" && bytes.HasPrefix(rest, []byte("//go:"))) &&
+			(!s.hashRule || c.line.Len() == 0 || isSpaceByte(c.line.Bytes()[c.line.Len()-1])):
+			n := bytes.IndexByte(rest, '\n')
+			if n < 0 {
+				n = len(rest)
+			}
+			c.hadComment = true
+			i += n
+		case s.blockStart != "" && bytes.HasPrefix(rest, []byte(s.blockStart)):
+			n := len(rest)
+			if end := bytes.Index(rest[len(s.blockStart):], []byte(s.blockEnd)); end >= 0 {
+				n = len(s.blockStart) + end + len(s.blockEnd)
+			}
+			// Lines inside the comment end up empty and are dropped
+			for _, b := range rest[:n] {
+				if b == '\n' {
+					c.hadComment = true
+					c.line.WriteByte('\n')
+					c.endLine()
+				}
+			}
+			c.hadComment = true
+			i += n
+		default:
+			c.write(rest[:1])
+			i++
+		}
+	}
+	c.endLine()
+	return c.out.Bytes()
+}
+
+func isSpaceByte(b byte) bool {
+	return b == ' ' || b == '\t'
+}
@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const minCompareColumn = 40 // Narrowest column worth a side-by-side layout
+
+// compareLine is a complete line of output from one of the compared models.
+// done marks the end of that model's output, with err set if it failed.
+type compareLine struct {
+	model int
+	text  string
+	done  bool
+	err   error
+}
+
+// runCompare sends payload to each of compareModels concurrently and streams
+// their answers as they arrive: in two columns when comparing two models on a
+// terminal wide enough for them, otherwise as interleaved lines labelled with
+// the model. Compared responses aren't cached.
+func runCompare(endpoint chatEndpoint, payload map[string]interface{}) error {
+	lines := make(chan compareLine)
+	client := newHTTPClient(180 * time.Second) // Reasonable timeout
+	var wg sync.WaitGroup
+	for i, model := range compareModels {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			streamCompared(client, endpoint, payload, i, model, lines)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(lines)
+	}()
+
+	width := 0
+	if stdoutIsTerminal() {
+		width, _, _ = term.GetSize(int(os.Stdout.Fd()))
+	}
+	var failed []string
+	if len(compareModels) == 2 && width >= 2*minCompareColumn+3 {
+		failed = printCompareColumns(lines, (width-3)/2)
+	} else {
+		failed = printCompareInterleaved(lines)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// streamCompared streams model's answer to payload, sending each complete
+// line to lines followed by a done line.
+func streamCompared(client *http.Client, endpoint chatEndpoint, payload map[string]interface{}, idx int, model string, lines chan<- compareLine) {
+	body := maps.Clone(payload)
+	body["model"] = model
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	resp, err := postChatCompletion(ctx, client, endpoint, body)
+	if err != nil {
+		lines <- compareLine{model: idx, done: true, err: err}
+		return
+	}
+	defer resp.Body.Close()
+
+	var pending strings.Builder
+	result := decodeStream(resp.Body, cancel, func(delta string) {
+		pending.WriteString(delta)
+		text := pending.String()
+		for {
+			nl := strings.IndexByte(text, '\n')
+			if nl < 0 {
+				break
+			}
+			lines <- compareLine{model: idx, text: text[:nl]}
+			text = text[nl+1:]
+		}
+		pending.Reset()
+		pending.WriteString(text)
+	}, false)
+	if pending.Len() > 0 {
+		lines <- compareLine{model: idx, text: pending.String()}
+	}
+	switch {
+	case result.stalled:
+		err = fmt.Errorf("stream stalled: no data received for %s", streamIdleTimeout)
+	case result.err != nil:
+		err = result.err
+	case result.errored:
+		err = fmt.Errorf("errors occurred during streaming; output may be incomplete")
+	case !result.finished:
+		err = fmt.Errorf("the stream ended before the model finished; output may be incomplete")
+	}
+	lines <- compareLine{model: idx, done: true, err: err}
+}
+
+// printCompareInterleaved prints each line as it arrives, labelled with its
+// model, and returns the failures.
+func printCompareInterleaved(lines <-chan compareLine) []string {
+	labelWidth := 0
+	for _, m := range compareModels {
+		labelWidth = max(labelWidth, len(m))
+	}
+	var failed []string
+	for l := range lines {
+		model := compareModels[l.model]
+		switch {
+		case l.done && l.err != nil:
+			failed = append(failed, fmt.Sprintf("%s: %v", model, l.err))
+			fmt.Printf("[%-*s] (failed: %v)\n", labelWidth, model, l.err)
+		case l.done:
+			fmt.Printf("[%-*s] (done)\n", labelWidth, model)
+		default:
+			fmt.Printf("[%-*s] %s\n", labelWidth, model, l.text)
+		}
+	}
+	return failed
+}
+
+// printCompareColumns prints the two models' answers side by side, each line
+// wrapped to width. A row is printed once both models have reached it (or one
+// has finished), so the faster model waits for the slower one line by line.
+func printCompareColumns(lines <-chan compareLine, width int) []string {
+	var queues [2][]string
+	var done [2]bool
+	var failed []string
+
+	printRow := func(left, right string) {
+		fmt.Printf("%s │ %s\n", padColumn(left, width), right)
+	}
+	printRow(compareModels[0], compareModels[1])
+	printRow(strings.Repeat("─", width), strings.Repeat("─", width))
+	flush := func() {
+		for (len(queues[0]) > 0 || done[0]) && (len(queues[1]) > 0 || done[1]) && (len(queues[0]) > 0 || len(queues[1]) > 0) {
+			var row [2]string
+			for i := range queues {
+				if len(queues[i]) > 0 {
+					row[i], queues[i] = queues[i][0], queues[i][1:]
+				}
+			}
+			printRow(row[0], row[1])
+		}
+	}
+
+	for l := range lines {
+		if l.done {
+			done[l.model] = true
+			if l.err != nil {
+				failed = append(failed, fmt.Sprintf("%s: %v", compareModels[l.model], l.err))
+				queues[l.model] = append(queues[l.model], wrapColumn(fmt.Sprintf("(failed: %v)", l.err), width)...)
+			}
+		} else {
+			queues[l.model] = append(queues[l.model], wrapColumn(l.text, width)...)
+		}
+		flush()
+	}
+	return failed
+}
+
+// wrapColumn splits a line into pieces of at most width runes, expanding tabs.
+func wrapColumn(text string, width int) []string {
+	runes := []rune(strings.ReplaceAll(text, "\t", "    "))
+	if len(runes) == 0 {
+		return []string{""}
+	}
+	var pieces []string
+	for len(runes) > width {
+		pieces = append(pieces, string(runes[:width]))
+		runes = runes[width:]
+	}
+	return append(pieces, string(runes))
+}
+
+// padColumn pads text with spaces to width runes.
+func padColumn(text string, width int) string {
+	if n := len([]rune(text)); n < width {
+		return text + strings.Repeat(" ", width-n)
+	}
+	return text
+}
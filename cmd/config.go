@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// vibeConfig is the user configuration read from ~/.config/vibe/config.json.
+// Every field is optional.
+type vibeConfig struct {
+	// Aliases maps short model names to full OpenRouter slugs,
+	// e.g. {"sonnet": "anthropic/claude-3.5-sonnet"}.
+	Aliases map[string]string `json:"aliases"`
+
+	// PromptPrefix and PromptSuffix are defaults for code's --prompt-prefix and
+	// --prompt-suffix. Like the flags, a value starting with @ names a file.
+	PromptPrefix string `json:"prompt_prefix"`
+	PromptSuffix string `json:"prompt_suffix"`
+
+	// MaxCost is the default for code's --max-cost, e.g. "$0.50" or 0.5.
+	MaxCost usdAmount `json:"max_cost"`
+
+	// History turns on the request log listed by vibe history.
+	History bool `json:"history"`
+
+	unknownKeys []string // Top-level keys vibe doesn't recognize, with suggestions
+}
+
+var (
+	loadedConfig *vibeConfig // Cached by loadConfig
+	strictConfig bool        // Flag to treat unknown config keys as an error
+)
+
+// vibeConfigDir returns the directory vibe reads user configuration from
+// (~/.config/vibe on Linux, honoring $XDG_CONFIG_HOME).
+func vibeConfigDir() (string, error) {
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate config directory: %w", err)
+	}
+	return filepath.Join(base, "vibe"), nil
+}
+
+// configPath returns the path of the config file.
+func configPath() (string, error) {
+	dir, err := vibeConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.json"), nil
+}
+
+// loadConfig reads the config file once per invocation. A missing file yields
+// an empty config; a malformed one is an error.
+func loadConfig() (*vibeConfig, error) {
+	if loadedConfig != nil {
+		return loadedConfig, nil
+	}
+	cfg := &vibeConfig{}
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read config %s: %w", path, err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+		}
+		cfg.unknownKeys = unknownConfigKeys(data)
+		if len(cfg.unknownKeys) > 0 {
+			msg := fmt.Sprintf("config %s has %s", path, strings.Join(cfg.unknownKeys, ", "))
+			if strictConfig {
+				return nil, fmt.Errorf("%s (remove them or drop --strict-config)", msg)
+			}
+			warnf("config", "%s; they are ignored\n", msg)
+		}
+	}
+	loadedConfig = cfg
+	return cfg, nil
+}
+
+// configKeys returns the top-level keys vibeConfig understands, from its JSON tags.
+func configKeys() []string {
+	var keys []string
+	t := reflect.TypeOf(vibeConfig{})
+	for i := 0; i < t.NumField(); i++ {
+		if name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ","); name != "" && name != "-" {
+			keys = append(keys, name)
+		}
+	}
+	return keys
+}
+
+// unknownConfigKeys describes each top-level key in data that vibeConfig
+// doesn't have, suggesting the closest valid key when one is near, e.g.
+// `unknown key "alias" (did you mean "aliases"?)`.
+func unknownConfigKeys(data []byte) []string {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil // Already reported by the typed unmarshal
+	}
+	known := configKeys()
+	var problems []string
+	for key := range raw {
+		found := false
+		for _, k := range known {
+			if strings.EqualFold(key, k) { // encoding/json matches keys case-insensitively
+				found = true
+				break
+			}
+		}
+		if found {
+			continue
+		}
+		problem := fmt.Sprintf("unknown key %q", key)
+		if near := nearestKey(key, known); near != "" {
+			problem += fmt.Sprintf(" (did you mean %q?)", near)
+		}
+		problems = append(problems, problem)
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+// nearestKey returns the candidate closest to key by edit distance, or "" if
+// none is close enough to be a plausible typo.
+func nearestKey(key string, candidates []string) string {
+	best, bestDist := "", len(key)/2+1
+	for _, c := range candidates {
+		if d := editDistance(strings.ToLower(key), c); d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// resolveModelAlias expands model through the config's aliases, returning it
+// unchanged if it isn't an alias.
+func resolveModelAlias(model string) (string, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return "", err
+	}
+	if full, ok := cfg.Aliases[model]; ok {
+		verbosef("Resolved model alias %q to %s\n", model, full)
+		return full, nil
+	}
+	return model, nil
+}
+
+// loadTextArg returns value, or the contents of the file it names if it starts
+// with @ (e.g. @~/prompts/suffix.txt). Trailing newlines are trimmed.
+func loadTextArg(value string) (string, error) {
+	if !strings.HasPrefix(value, "@") {
+		return value, nil
+	}
+	path := strings.TrimPrefix(value, "@")
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, rest)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
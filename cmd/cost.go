@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	maxCost   usdAmount // Flag for the estimated request cost above which code asks before sending
+	assumeYes bool      // Flag to send expensive requests without asking
+)
+
+// usdAmount is a dollar amount written as "0.50" or "$0.50", on the command
+// line or in config.json (where a plain number works too).
+type usdAmount float64
+
+func (a *usdAmount) String() string {
+	if *a == 0 {
+		return "0"
+	}
+	return fmt.Sprintf("$%.2f", float64(*a))
+}
+
+func (a *usdAmount) Set(s string) error {
+	v, err := strconv.ParseFloat(strings.TrimPrefix(strings.TrimSpace(s), "$"), 64)
+	if err != nil || v < 0 {
+		return fmt.Errorf("invalid amount %q: want a dollar amount like $0.50", s)
+	}
+	*a = usdAmount(v)
+	return nil
+}
+
+func (a *usdAmount) Type() string {
+	return "usd"
+}
+
+func (a *usdAmount) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		return a.Set(s)
+	}
+	var v float64
+	if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("invalid amount %s: want a dollar amount like \"$0.50\"", data)
+	}
+	return a.Set(strconv.FormatFloat(v, 'f', -1, 64))
+}
+
+// estimateRequestCost estimates what sending promptChars of messages to model
+// costs, from OpenRouter's per-token prices. Completion tokens are only
+// counted when --max-tokens bounds them, so the estimate is a lower bound.
+func estimateRequestCost(model string, promptChars int) (float64, error) {
+	models, err := openRouterModels(false)
+	if err != nil {
+		return 0, err
+	}
+	for _, m := range models {
+		if m.ID != model {
+			continue
+		}
+		promptPrice, err := strconv.ParseFloat(m.Pricing.Prompt, 64)
+		if err != nil {
+			return 0, fmt.Errorf("model %s has no prompt price", model)
+		}
+		cost := promptPrice * float64(estimateTokens(promptChars))
+		if completionPrice, err := strconv.ParseFloat(m.Pricing.Completion, 64); err == nil && maxTokens > 0 {
+			cost += completionPrice * float64(maxTokens*max(completionCount, 1))
+		}
+		return cost, nil
+	}
+	return 0, fmt.Errorf("model %s is not in OpenRouter's models list", model)
+}
+
+// confirmCost checks the estimated cost of sending the request to model against --max-cost (or
+// the config's max_cost). Over the limit it asks on the terminal, unless --yes
+// is given; without a terminal to ask on it refuses.
+func confirmCost(cmd *cobra.Command, endpoint chatEndpoint, model string, promptChars int) error {
+	limit := maxCost
+	if !cmd.Flags().Changed("max-cost") {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		limit = cfg.MaxCost
+	}
+	if limit <= 0 {
+		return nil
+	}
+	if endpoint.name != "OpenRouter" {
+		verbosef("Skipping the --max-cost check: prices are only known for OpenRouter models\n")
+		return nil
+	}
+	cost, err := estimateRequestCost(model, promptChars)
+	if err != nil {
+		warnf("cost", "Could not estimate the request cost, skipping the --max-cost check: %v\n", err)
+		return nil
+	}
+	verbosef("Estimated request cost: $%.4f\n", cost)
+	if cost <= float64(limit) {
+		return nil
+	}
+
+	msg := fmt.Sprintf("estimated cost $%.2f for %s is over the limit of %s", cost, model, limit.String())
+	if assumeYes {
+		logf("Sending anyway (--yes): %s.\n", msg)
+		return nil
+	}
+	if !stdinIsTerminal() {
+		return fmt.Errorf("%s; pass --yes to send it anyway", msg)
+	}
+	fmt.Fprintf(os.Stderr, "The %s. Send it? [y/N] ", msg)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+		return fmt.Errorf("request not sent")
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/spf13/cobra"
+)
+
+var (
+	diffContextSave    string // Flag for the snapshot file to write
+	diffContextAgainst string // Flag for the snapshot file to compare against
+)
+
+// contextSnapshot is a saved context gathering, as written by `vibe diff-context --save`.
+type contextSnapshot struct {
+	Root      string         `json:"root"`
+	CreatedAt time.Time      `json:"created_at"`
+	Files     []snapshotFile `json:"files"`
+}
+
+// snapshotFile is one gathered file; Path is relative to the snapshot root so
+// snapshots can be compared across checkouts.
+type snapshotFile struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+}
+
+// diffContextCmd represents the diff-context command
+var diffContextCmd = &cobra.Command{
+	Use:   "diff-context [directory]",
+	Short: "Save or compare the file context 'vibe code' would send",
+	Long: `Gathers context from the directory (or current directory) exactly as 'vibe code' does
+and either saves it as a snapshot or compares it against an earlier snapshot.
+
+Use this to understand why a prompt's answer changed between runs: the output lists
+files that were added to or removed from the context, followed by a unified diff of
+every file whose content changed.
+
+Example:
+  vibe diff-context --save before.json .
+  # ...edit files, switch branches...
+  vibe diff-context --against before.json .`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if (diffContextSave == "") == (diffContextAgainst == "") {
+			return fmt.Errorf("exactly one of --save or --against is required")
+		}
+		targetDir := "."
+		if len(args) == 1 {
+			targetDir = args[0]
+		}
+		absTargetDir, err := resolveTargetDir(targetDir)
+		if err != nil {
+			return err
+		}
+
+		logf("Gathering context from: %s\n", absTargetDir)
+		current, err := snapshotContext(absTargetDir)
+		if err != nil {
+			return err
+		}
+
+		if diffContextSave != "" {
+			data, err := json.MarshalIndent(current, "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to marshal context snapshot: %w", err)
+			}
+			if err := os.WriteFile(diffContextSave, data, 0o644); err != nil {
+				return fmt.Errorf("failed to write snapshot %s: %w", diffContextSave, err)
+			}
+			logf("Saved context snapshot of %d file(s) to %s\n", len(current.Files), diffContextSave)
+			return nil
+		}
+
+		data, err := os.ReadFile(diffContextAgainst)
+		if err != nil {
+			return fmt.Errorf("failed to read snapshot %s: %w", diffContextAgainst, err)
+		}
+		var previous contextSnapshot
+		if err := json.Unmarshal(data, &previous); err != nil {
+			return fmt.Errorf("failed to parse snapshot %s: %w", diffContextAgainst, err)
+		}
+		return printContextDiff(previous, *current)
+	},
+}
+
+// snapshotContext gathers context from root with code's filters.
+func snapshotContext(root string) (*contextSnapshot, error) {
+	files, _, err := gatherFiles(codeWalkOptions(root))
+	if err != nil {
+		return nil, fmt.Errorf("error walking the path %q: %w", root, err)
+	}
+	snap := &contextSnapshot{Root: root, CreatedAt: time.Now()}
+	for _, f := range files {
+		rel, err := filepath.Rel(root, f.path)
+		if err != nil {
+			rel = f.path
+		}
+		snap.Files = append(snap.Files, snapshotFile{Path: filepath.ToSlash(rel), Content: string(f.content)})
+	}
+	return snap, nil
+}
+
+// printContextDiff prints the file-list changes and per-file unified diffs between two snapshots.
+func printContextDiff(previous, current contextSnapshot) error {
+	before := map[string]string{}
+	for _, f := range previous.Files {
+		before[f.Path] = f.Content
+	}
+	after := map[string]string{}
+	for _, f := range current.Files {
+		after[f.Path] = f.Content
+	}
+
+	var added, removed, changed []string
+	for path, content := range after {
+		old, ok := before[path]
+		switch {
+		case !ok:
+			added = append(added, path)
+		case old != content:
+			changed = append(changed, path)
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			removed = append(removed, path)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	sort.Strings(changed)
+
+	fmt.Printf("Comparing snapshot from %s (%d files) with current context (%d files)\n",
+		previous.CreatedAt.Format(time.RFC3339), len(previous.Files), len(current.Files))
+	if len(added)+len(removed)+len(changed) == 0 {
+		fmt.Println("No differences.")
+		return nil
+	}
+	for _, p := range added {
+		fmt.Printf("+ %s\n", p)
+	}
+	for _, p := range removed {
+		fmt.Printf("- %s\n", p)
+	}
+	for _, p := range changed {
+		fmt.Printf("~ %s\n", p)
+	}
+
+	for _, p := range changed {
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(before[p]),
+			B:        difflib.SplitLines(after[p]),
+			FromFile: "a/" + p,
+			ToFile:   "b/" + p,
+			Context:  3,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to diff %s: %w", p, err)
+		}
+		fmt.Print("\n" + diff)
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(diffContextCmd)
+	diffContextCmd.Flags().StringVar(&diffContextSave, "save", "", "Write the gathered context to this snapshot file")
+	diffContextCmd.Flags().StringVar(&diffContextAgainst, "against", "", "Compare the gathered context with this snapshot file")
+	addWalkFlags(diffContextCmd)
+}
@@ -0,0 +1,248 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var skipNetworkChecks bool // Flag to skip the endpoint reachability checks
+
+// checkStatus is the outcome of a single doctor check.
+type checkStatus int
+
+const (
+	checkOK checkStatus = iota
+	checkWarn
+	checkFail
+)
+
+// doctorCheck is one line of the doctor checklist.
+type doctorCheck struct {
+	name   string
+	status checkStatus
+	detail string
+}
+
+// doctorEndpoints are the API hosts vibe talks to, probed for reachability.
+var doctorEndpoints = []struct{ name, url string }{
+	{"OpenRouter", openRouterModelsURL},
+	{"OpenAI", "https://api.openai.com/v1/models"},
+	{"Anthropic", "https://api.anthropic.com/v1/models"},
+}
+
+// doctorCmd represents the doctor command
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check API keys, network access, terminal capabilities and config",
+	Long: `Runs a series of checks for common setup problems and prints a checklist:
+
+- API keys: OPENROUTER_API_KEY (code, gen), OPENAI_API_KEY and ANTHROPIC_API_KEY (gen).
+- Network: whether each provider's API endpoint can be reached (skip with --skip-network).
+- Terminal: whether stdout is a TTY, color support, and how likely OSC 52 clipboard
+  copies (used by gemini over SSH) are to work, based on $TERM, $TERM_PROGRAM and tmux.
+- Config: the resolved config file and templates directory, and whether they parse.
+
+Exits with an error if any check fails; warnings don't affect the exit status.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true, // A failed check isn't a usage error
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var checks []doctorCheck
+		checks = append(checks, apiKeyChecks()...)
+		if !skipNetworkChecks {
+			checks = append(checks, networkChecks()...)
+		}
+		checks = append(checks, terminalChecks()...)
+		checks = append(checks, configChecks()...)
+
+		color := stdoutIsTerminal() && os.Getenv("NO_COLOR") == ""
+		failures := 0
+		for _, c := range checks {
+			if c.status == checkFail {
+				failures++
+			}
+			fmt.Println(formatCheck(c, color))
+		}
+
+		if failures > 0 {
+			return fmt.Errorf("doctor found %d problem(s)", failures)
+		}
+		return nil
+	},
+}
+
+// formatCheck renders a check as a checklist line, colored if color is set.
+func formatCheck(c doctorCheck, color bool) string {
+	marks := map[checkStatus]struct{ mark, ansi string }{
+		checkOK:   {"✓", "\033[32m"},
+		checkWarn: {"!", "\033[33m"},
+		checkFail: {"✗", "\033[31m"},
+	}
+	m := marks[c.status]
+	mark := m.mark
+	if color {
+		mark = m.ansi + mark + "\033[0m"
+	}
+	line := fmt.Sprintf("%s %s", mark, c.name)
+	if c.detail != "" {
+		line += ": " + c.detail
+	}
+	return line
+}
+
+// apiKeyChecks reports which provider API keys are set.
+func apiKeyChecks() []doctorCheck {
+	keys := []struct {
+		env, usedBy string
+		required    bool
+	}{
+		{apiKeyEnvVar, "code, gen", true},
+		{"OPENAI_API_KEY", "gen", false},
+		{"ANTHROPIC_API_KEY", "gen", false},
+	}
+	var checks []doctorCheck
+	for _, k := range keys {
+		c := doctorCheck{name: k.env, status: checkOK, detail: "set"}
+		if os.Getenv(k.env) == "" {
+			c.status = checkWarn
+			if k.required {
+				c.status = checkFail
+			}
+			c.detail = "not set (needed by " + k.usedBy + ")"
+		}
+		checks = append(checks, c)
+	}
+	return checks
+}
+
+// networkChecks probes each API endpoint in parallel. Any HTTP response,
+// even 401, means the host is reachable.
+func networkChecks() []doctorCheck {
+	checks := make([]doctorCheck, len(doctorEndpoints))
+	client := newHTTPClient(5 * time.Second)
+	var wg sync.WaitGroup
+	for i, ep := range doctorEndpoints {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c := doctorCheck{name: "Reach " + ep.name, status: checkOK}
+			resp, err := client.Get(ep.url)
+			if err != nil {
+				c.status = checkFail
+				c.detail = err.Error()
+			} else {
+				resp.Body.Close()
+				c.detail = fmt.Sprintf("%s (HTTP %d)", ep.url, resp.StatusCode)
+			}
+			checks[i] = c
+		}()
+	}
+	wg.Wait()
+	return checks
+}
+
+// terminalChecks reports TTY, color and OSC 52 support.
+func terminalChecks() []doctorCheck {
+	var checks []doctorCheck
+
+	if stdoutIsTerminal() {
+		checks = append(checks, doctorCheck{name: "Terminal", status: checkOK, detail: "stdout is a TTY"})
+	} else {
+		checks = append(checks, doctorCheck{name: "Terminal", status: checkWarn, detail: "stdout is not a TTY; Markdown is rendered without styling (notty)"})
+	}
+
+	term := os.Getenv("TERM")
+	switch {
+	case os.Getenv("NO_COLOR") != "":
+		checks = append(checks, doctorCheck{name: "Color", status: checkWarn, detail: "disabled by NO_COLOR"})
+	case term == "" || term == "dumb":
+		checks = append(checks, doctorCheck{name: "Color", status: checkWarn, detail: fmt.Sprintf("TERM=%q does not support color", term)})
+	case os.Getenv("COLORTERM") == "truecolor" || os.Getenv("COLORTERM") == "24bit":
+		checks = append(checks, doctorCheck{name: "Color", status: checkOK, detail: "truecolor"})
+	default:
+		checks = append(checks, doctorCheck{name: "Color", status: checkOK, detail: "TERM=" + term})
+	}
+
+	checks = append(checks, osc52Check(term))
+	return checks
+}
+
+// osc52Check guesses whether the terminal will honor OSC 52 clipboard escapes.
+func osc52Check(term string) doctorCheck {
+	c := doctorCheck{name: "OSC 52 clipboard"}
+	context := "local session"
+	if isRunningViaSSH() {
+		context = "SSH session"
+	}
+
+	program := os.Getenv("TERM_PROGRAM")
+	knownGood := []string{"iTerm.app", "WezTerm", "vscode", "ghostty"}
+	switch {
+	case os.Getenv("TMUX") != "":
+		c.status = checkWarn
+		c.detail = context + " inside tmux; OSC 52 needs 'set -g set-clipboard on' in tmux.conf"
+	case strings.HasPrefix(term, "screen"):
+		c.status = checkWarn
+		c.detail = context + " inside screen, which usually drops OSC 52"
+	case os.Getenv("WT_SESSION") != "" || os.Getenv("KITTY_WINDOW_ID") != "" ||
+		strings.Contains(term, "kitty") || strings.Contains(term, "alacritty") || slices.Contains(knownGood, program):
+		c.status = checkOK
+		c.detail = context + " in a terminal known to support OSC 52"
+	default:
+		c.status = checkWarn
+		c.detail = context + "; unknown terminal, OSC 52 may not work (TERM=" + term + ", TERM_PROGRAM=" + program + ")"
+	}
+	return c
+}
+
+// configChecks reports the resolved config file and templates directory.
+func configChecks() []doctorCheck {
+	var checks []doctorCheck
+	path, err := configPath()
+	if err != nil {
+		return append(checks, doctorCheck{name: "Config", status: checkFail, detail: err.Error()})
+	}
+	switch _, statErr := os.Stat(path); {
+	case os.IsNotExist(statErr):
+		checks = append(checks, doctorCheck{name: "Config", status: checkOK, detail: path + " (not present, using defaults)"})
+	default:
+		if cfg, err := loadConfig(); err != nil {
+			checks = append(checks, doctorCheck{name: "Config", status: checkFail, detail: err.Error()})
+		} else if len(cfg.unknownKeys) > 0 {
+			checks = append(checks, doctorCheck{name: "Config", status: checkWarn, detail: path + ": " + strings.Join(cfg.unknownKeys, ", ")})
+		} else {
+			checks = append(checks, doctorCheck{name: "Config", status: checkOK, detail: path})
+		}
+	}
+
+	if dir, err := templatesDir(); err == nil {
+		detail := dir
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			detail += " (not present)"
+		}
+		checks = append(checks, doctorCheck{name: "Templates", status: checkOK, detail: detail})
+	}
+	return checks
+}
+
+// stdoutIsTerminal reports whether stdout is a character device (a TTY).
+func stdoutIsTerminal() bool {
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// stdinIsTerminal reports whether stdin is a character device (a TTY).
+func stdinIsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+	doctorCmd.Flags().BoolVar(&skipNetworkChecks, "skip-network", false, "Skip the API endpoint reachability checks")
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+)
+
+var editPrompt bool // Flag to compose the prompt in $EDITOR
+
+// editorPromptHelp follows the prompt in the file opened by --edit, like the
+// comment block git commit puts below the message.
+const editorPromptHelp = `
+# Write your request above. Lines starting with '#' are notes for yourself
+# and are not sent; an empty request aborts.
+#
+# The file context is gathered from %s
+# and sent along with the request.
+`
+
+// promptFromEditor opens $EDITOR on a file holding initial and a short help
+// block, waits for it to exit and returns what was saved, without the '#'
+// lines. It is an error if $EDITOR is unset or the request comes back empty.
+func promptFromEditor(initial, targetDir string) (string, error) {
+	editor := strings.TrimSpace(os.Getenv("EDITOR"))
+	if editor == "" {
+		return "", fmt.Errorf("--edit needs $EDITOR to be set, e.g. EDITOR=vim")
+	}
+	file, err := os.CreateTemp("", "vibe-prompt-*.md")
+	if err != nil {
+		return "", fmt.Errorf("failed to create the prompt file: %w", err)
+	}
+	defer os.Remove(file.Name())
+	_, err = fmt.Fprintf(file, "%s\n"+editorPromptHelp, initial, targetDir)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to write the prompt file: %w", err)
+	}
+
+	// The editor gets the file as a shell argument, so $EDITOR may carry its
+	// own flags ("code --wait")
+	c := shellCommand(editor + " " + shellQuote(file.Name()))
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	if err := c.Run(); err != nil {
+		return "", fmt.Errorf("editor %q failed: %w", editor, err)
+	}
+	data, err := os.ReadFile(file.Name())
+	if err != nil {
+		return "", fmt.Errorf("failed to read the prompt file: %w", err)
+	}
+
+	var kept []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, "#") {
+			kept = append(kept, strings.TrimRight(line, "\r"))
+		}
+	}
+	prompt := strings.TrimSpace(strings.Join(kept, "\n"))
+	if prompt == "" {
+		return "", fmt.Errorf("aborting: the request is empty")
+	}
+	verbosef("Prompt from $EDITOR: %s\n", prompt)
+	return prompt, nil
+}
+
+// shellQuote quotes path for shellCommand's shell.
+func shellQuote(path string) string {
+	if runtime.GOOS == "windows" {
+		return `"` + path + `"`
+	}
+	return "'" + strings.ReplaceAll(path, "'", `'\''`) + "'"
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"bytes"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+var (
+	utf8BOM    = []byte{0xEF, 0xBB, 0xBF}
+	utf16LEBOM = []byte{0xFF, 0xFE}
+	utf16BEBOM = []byte{0xFE, 0xFF}
+)
+
+// normalizeEncoding returns content as BOM-less UTF-8, so byte order marks and
+// mojibake don't leak into prompts. UTF-16 files (recognized by their BOM) are
+// converted, files containing NUL bytes are taken to be binary (ok is false and
+// a warning is printed), and other invalid UTF-8 is assumed to be Latin-1 and
+// transcoded.
+func normalizeEncoding(content []byte, displayPath string) (normalized []byte, ok bool) {
+	switch {
+	case bytes.HasPrefix(content, utf8BOM):
+		return content[len(utf8BOM):], true
+	case bytes.HasPrefix(content, utf16LEBOM), bytes.HasPrefix(content, utf16BEBOM):
+		verbosef("Converting %s from UTF-16 to UTF-8\n", displayPath)
+		return decodeUTF16(content[2:], content[0] == 0xFE), true
+	case bytes.IndexByte(content, 0) >= 0: // Valid UTF-8, but text files don't contain NULs
+		warnf("binary", "Skipping %s: looks like a binary file\n", displayPath)
+		return nil, false
+	case utf8.Valid(content):
+		return content, true
+	}
+	verbosef("Transcoding %s from Latin-1 to UTF-8 (not valid UTF-8)\n", displayPath)
+	var b bytes.Buffer
+	b.Grow(len(content) + len(content)/8)
+	for _, c := range content {
+		b.WriteRune(rune(c)) // Latin-1 bytes are the first 256 code points
+	}
+	return b.Bytes(), true
+}
+
+// normalizeLineEndings converts CRLF line endings to LF unless --keep-crlf is
+// set, so Windows files don't carry a \r on every line into the prompt.
+func normalizeLineEndings(content []byte, displayPath string) []byte {
+	if keepCRLF || !bytes.Contains(content, []byte("\r\n")) {
+		return content
+	}
+	verbosef("Converting CRLF line endings in %s to LF\n", displayPath)
+	return bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+}
+
+// decodeUTF16 converts UTF-16 data without its BOM to UTF-8. A trailing odd
+// byte is dropped.
+func decodeUTF16(data []byte, bigEndian bool) []byte {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+		} else {
+			units[i] = uint16(data[2*i+1])<<8 | uint16(data[2*i])
+		}
+	}
+	return []byte(string(utf16.Decode(units)))
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNormalizeEncoding(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []byte
+		want    []byte
+		wantOK  bool
+	}{
+		{"plain UTF-8", []byte("héllo\n"), []byte("héllo\n"), true},
+		{"UTF-8 BOM", []byte("\xEF\xBB\xBFhéllo\n"), []byte("héllo\n"), true},
+		{"UTF-16LE BOM", []byte("\xFF\xFEh\x00\xE9\x00\n\x00"), []byte("hé\n"), true},
+		{"UTF-16BE BOM", []byte("\xFE\xFF\x00h\x00\xE9\x00\n"), []byte("hé\n"), true},
+		{"UTF-16LE surrogate pair", []byte("\xFF\xFE\x3D\xD8\x00\xDE"), []byte("😀"), true},
+		{"Latin-1", []byte("caf\xE9 na\xEFve\n"), []byte("café naïve\n"), true},
+		{"NUL byte", []byte("ELF\x00\x01\x02"), nil, false},
+		{"empty", []byte{}, []byte{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := normalizeEncoding(tt.content, "test.txt")
+			if ok != tt.wantOK {
+				t.Fatalf("normalizeEncoding(%q) ok = %v, want %v", tt.content, ok, tt.wantOK)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("normalizeEncoding(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var showExamples bool // Flag to print a command's example invocations instead of running it
+
+// commandExample is one invocation printed by --examples. In args, {model}
+// and {model2} stand for models to show, taken from config.json's aliases
+// when it has any.
+type commandExample struct {
+	desc string
+	args string
+}
+
+// commandExamples is the --examples table, in the order the commands are
+// listed by help. Each command with an entry gets the flag.
+var commandExamples = []struct {
+	cmd      *cobra.Command
+	examples []commandExample
+}{
+	{codeCmd, []commandExample{
+		{"Ask about the project in the current directory", `code "explain how requests are routed" .`},
+		{"Use another model (or an alias from config.json)", `code "review the error handling" . -m {model}`},
+		{"Focus on part of the tree", `code "simplify the handlers" . --context-glob '**/handlers/*.go'`},
+		{"Gather from two services without the rest of the repo", `code "how do these services talk?" --dir services/auth --dir services/billing`},
+		{"Always include a file, even one the filters would skip", `code "why is this build failing?" . --file Makefile`},
+		{"Send only what changed on this branch", `code "review this change" . --context-from-git-diff --base main`},
+		{"Write the files in the response to disk, leaving dirty files alone", `code "add a String method to Config" . --apply --only-clean`},
+		{"Dry run: list the files that would be sent, without calling a model", `show . --paths-only`},
+		{"Save the request body to inspect it", `code "add tests for lib/a.go" . --save-request request.json`},
+		{"Compare two models on the same prompt", `code "explain the main package" . --compare {model},{model2}`},
+		{"Keep asking follow-up questions about the same context", `code --interactive .`},
+	}},
+	{diffContextCmd, []commandExample{
+		{"Snapshot the context code would send", `diff-context --save before.json .`},
+		{"See what changed in it since the snapshot", `diff-context --against before.json .`},
+	}},
+	{doctorCmd, []commandExample{
+		{"Check the API keys, config and network", `doctor`},
+		{"Skip the checks that call the network", `doctor --skip-network`},
+	}},
+	{geminiCmd, []commandExample{
+		{"Copy the context and open Gemini", `gemini .`},
+		{"Leave the persona line out of the context", `gemini . --no-persona`},
+	}},
+	{genCmd, []commandExample{
+		{"Query every provider and merge the answers", `gen prompt.txt`},
+		{"Only ask some providers", `gen prompt.txt --providers claude,openai`},
+		{"Include a local model through Ollama", `gen prompt.txt --ollama-model llama3.2`},
+		{"Watch the responses arrive", `gen prompt.txt --stream`},
+		{"Get the responses as JSON for a script", `gen prompt.txt --format json`},
+	}},
+	{historyCmd, []commandExample{
+		{"List the latest requests", `history`},
+		{"Find past requests about a topic, with their responses", `history "rate limit" --full`},
+	}},
+	{modelsCmd, []commandExample{
+		{"List the Anthropic models and their prices", `models anthropic`},
+		{"Refetch the list instead of using the cache", `models --refresh`},
+	}},
+	{showCmd, []commandExample{
+		{"Print the context gathered from a directory", `show .`},
+		{"Only list the files that pass the filters", `show . --paths-only`},
+		{"Render the files as Markdown", `show ./lib --format markdown`},
+		{"Get the files as JSON for a script", `show . --format json`},
+	}},
+}
+
+func init() {
+	for _, entry := range commandExamples {
+		addExamplesFlag(entry.cmd, entry.examples)
+	}
+}
+
+// addExamplesFlag gives cmd an --examples flag that prints examples instead of
+// running it, skipping argument validation so that no arguments are needed.
+func addExamplesFlag(cmd *cobra.Command, examples []commandExample) {
+	cmd.Flags().BoolVar(&showExamples, "examples", false, "Print example invocations of this command and exit")
+	args, runE := cmd.Args, cmd.RunE
+	cmd.Args = func(cmd *cobra.Command, a []string) error {
+		if showExamples || args == nil {
+			return nil
+		}
+		return args(cmd, a)
+	}
+	cmd.RunE = func(cmd *cobra.Command, a []string) error {
+		if showExamples {
+			printExamples(cmd, examples)
+			return nil
+		}
+		return runE(cmd, a)
+	}
+}
+
+// printExamples prints examples for cmd, substituting the example models.
+func printExamples(cmd *cobra.Command, examples []commandExample) {
+	models := exampleModels()
+	r := strings.NewReplacer("{model}", models[0], "{model2}", models[1])
+	fmt.Printf("Examples for %s:\n", cmd.CommandPath())
+	for _, ex := range examples {
+		fmt.Printf("\n  # %s\n  %s %s\n", ex.desc, cmd.Root().Name(), r.Replace(ex.args))
+	}
+}
+
+// exampleModels returns two models to use in examples: the first aliases in
+// config.json, so that the examples match the user's setup, padded with the
+// default model and another popular one.
+func exampleModels() []string {
+	var models []string
+	if cfg, err := loadConfig(); err == nil {
+		for alias := range cfg.Aliases {
+			models = append(models, alias)
+		}
+		sort.Strings(models)
+	}
+	models = append(models, defaultModel, "openai/gpt-4o")
+	return models[:2]
+}
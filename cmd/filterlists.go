@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	allowlistFile string // Flag for a file of include globs, one per line
+	denylistFile  string // Flag for a file of exclude globs, one per line
+)
+
+// Pattern files picked up from the target directory when the flags aren't
+// given, so a team can commit them and everyone gathers the same context.
+const (
+	defaultAllowlist = "vibe.allow"
+	defaultDenylist  = "vibe.deny"
+)
+
+// filterGlobs returns the include and exclude globs for a walk of root:
+// --context-glob and --exclude-glob plus the patterns in --allowlist-file and
+// --denylist-file, or in root's vibe.allow and vibe.deny when those flags
+// aren't given.
+func filterGlobs(root string) (include, exclude []string, err error) {
+	include = append([]string{}, contextGlobs...)
+	exclude = append([]string{}, excludeGlobs...)
+	lists := []struct {
+		flag, path, fallback string
+		globs                *[]string
+	}{
+		{"--allowlist-file", allowlistFile, defaultAllowlist, &include},
+		{"--denylist-file", denylistFile, defaultDenylist, &exclude},
+	}
+	for _, l := range lists {
+		path := l.path
+		if path == "" {
+			path = filepath.Join(root, l.fallback)
+			if _, err := os.Stat(path); err != nil {
+				continue // No shared list in this directory
+			}
+			verbosef("Using patterns from %s\n", path)
+		}
+		patterns, err := readPatternFile(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", l.flag, err)
+		}
+		*l.globs = append(*l.globs, patterns...)
+	}
+	return include, exclude, nil
+}
+
+// readPatternFile reads one glob per line, ignoring blank lines and lines
+// starting with #.
+func readPatternFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var patterns []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			patterns = append(patterns, line)
+		}
+	}
+	return patterns, scanner.Err()
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// outputFormat is the --format value shared by code, gen and show:
+// "markdown" (rendered for the terminal), "text" (plain) or "json"
+// (structured, for scripts).
+type outputFormat string
+
+const (
+	formatMarkdown outputFormat = "markdown"
+	formatText     outputFormat = "text"
+	formatJSON     outputFormat = "json"
+)
+
+// outputFormats lists the accepted --format values.
+var outputFormats = []string{string(formatMarkdown), string(formatText), string(formatJSON)}
+
+// Each command's --format, with its historical output as the default
+var (
+	codeFormat = formatText
+	genFormat  = formatMarkdown
+	showFormat = formatText
+)
+
+func (f *outputFormat) String() string {
+	return string(*f)
+}
+
+func (f *outputFormat) Set(s string) error {
+	if !slices.Contains(outputFormats, s) {
+		return fmt.Errorf("must be one of %s", strings.Join(outputFormats, ", "))
+	}
+	*f = outputFormat(s)
+	return nil
+}
+
+func (f *outputFormat) Type() string {
+	return "format"
+}
+
+// printJSON writes v to stdout as indented JSON.
+func printJSON(v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON output: %w", err)
+	}
+	_, err = fmt.Fprintln(os.Stdout, string(data))
+	return err
+}
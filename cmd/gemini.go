@@ -3,16 +3,20 @@ package cmd
 import (
 	"encoding/base64"
 	"fmt"
-	"io/fs"
 	"os"
-	"path/filepath"
 	"strings"
 
-	"github.com/atotto/clipboard"
-	"github.com/pkg/browser"
 	"github.com/spf13/cobra"
 )
 
+var (
+	noPersona   bool   // Flag to omit the persona line from the copied context
+	personaText string // Flag for the persona line appended to the copied context
+)
+
+// defaultPersona is the line gemini appends to the context unless told otherwise.
+const defaultPersona = "Take on the persona of a distinguished software engineer."
+
 // isRunningViaSSH checks for common SSH environment variables.
 func isRunningViaSSH() bool {
 	return os.Getenv("SSH_CLIENT") != "" || os.Getenv("SSH_TTY") != "" || os.Getenv("SSH_CONNECTION") != ""
@@ -48,105 +52,74 @@ Behavior when run via SSH:
 - Prints the Gemini URL and instructions to standard error.
 - Skips direct remote clipboard/browser operations.
 
+Behavior under WSL (detected via /proc/version or $WSL_DISTRO_NAME):
+- Copies the context with Windows' clip.exe instead of the Linux clipboard, which
+  usually isn't available. If clip.exe fails, falls back to OSC 52 (supported by
+  Windows Terminal).
+- Opens the Windows browser via wslview if installed, otherwise via cmd.exe start.
+
+The context ends with a persona line ("` + defaultPersona + `").
+Use --persona to change it, or --no-persona to leave it out. Use --footer-file
+to end the context with the content of a file instead.
+
 Filtering logic is the same as 'vibe show' default.`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		targetDir := args[0]
 		inSSH := isRunningViaSSH()
+		inWSL := !inSSH && isRunningUnderWSL()
 
 		// --- 1. Validate Target Directory ---
-		absTargetDir, err := filepath.Abs(targetDir)
+		absTargetDir, err := resolveTargetDir(targetDir)
 		if err != nil {
-			return fmt.Errorf("failed to get absolute path for %s: %w", targetDir, err)
-		}
-		info, err := os.Stat(absTargetDir)
-		if err != nil {
-			if os.IsNotExist(err) {
-				return fmt.Errorf("directory not found: %s", absTargetDir)
-			}
-			return fmt.Errorf("failed to stat %s: %w", absTargetDir, err)
-		}
-		if !info.IsDir() {
-			return fmt.Errorf("path is not a directory: %s", absTargetDir)
+			return err
 		}
 
 		// --- User Feedback ---
-		fmt.Fprintf(os.Stderr, "Gathering context from: %s\n", absTargetDir)
+		logf("Gathering context from: %s\n", absTargetDir)
 		if inSSH {
-			fmt.Fprintln(os.Stderr, "(Running in SSH session, attempting OSC 52 copy to local clipboard...)")
+			logln("(Running in SSH session, attempting OSC 52 copy to local clipboard...)")
 		} else {
-			fmt.Fprintln(os.Stderr, "Applying default filters (like 'vibe show')...")
+			logln("Applying default filters (like 'vibe show')...")
 		}
 
 		// --- 2. Gather Context ---
-		var contextBuilder strings.Builder
-		filesCollected := 0
-		skippedDirs := 0
-		skipDirs := map[string]bool{".git": true, "node_modules": true, "vendor": true, "__pycache__": true, "venv": true, ".venv": true, "target": true, "build": true, "dist": true}
-
-		walkErr := filepath.WalkDir(absTargetDir, func(path string, d fs.DirEntry, walkErr error) error {
-			// Basic error handling
-			if walkErr != nil {
-				fmt.Fprintf(os.Stderr, "Warning: Error accessing path %q: %v\n", path, walkErr)
-				if d != nil && d.IsDir() {
-					return filepath.SkipDir
-				}
-				return nil
-			}
-
-			// Skip directories
-			if d.IsDir() {
-				dirName := d.Name()
-				if (strings.HasPrefix(dirName, ".") && dirName != ".") || skipDirs[dirName] {
-					skippedDirs++
-					return filepath.SkipDir
-				}
-				return nil
-			}
+		files, _, walkErr := gatherFiles(walkOptions{
+			root:        absTargetDir,
+			maxFileSize: 5 * 1024 * 1024, // Skip large files
+			skipEmpty:   true,
+			dedup:       true,
+			normalize:   true,
+			skipDir:     geminiSkipDir,
+			includeFile: geminiIncludeFile,
+		})
+		if walkErr != nil {
+			return fmt.Errorf("error during directory traversal of %q: %w", absTargetDir, walkErr)
+		}
 
-			// File Filtering
-			fileName := d.Name()
-			isHidden := strings.HasPrefix(fileName, ".")
-			isTestFile := strings.HasSuffix(fileName, "_test.go")
-			isModFile := fileName == "go.mod"
-			isSumFile := fileName == "go.sum"
-			isLicense := fileName == "LICENSE"
-			isMarkdown := strings.HasSuffix(strings.ToLower(fileName), ".md")
-			hasNoExtension := !strings.Contains(fileName, ".")
-			if isTestFile || isModFile || isSumFile || isLicense || isMarkdown || isHidden || hasNoExtension {
-				return nil
-			}
+		// The persona line is the default footer, which --footer-file replaces
+		persona := personaText
+		if noPersona {
+			persona = ""
+		}
+		footer, err := loadFooter(persona)
+		if err != nil {
+			return err
+		}
 
-			// Read/Append File Content
-			absPath, pathErr := filepath.Abs(path)
-			if pathErr != nil {
-				absPath = path /* fallback */
-			}
-			fileInfo, statErr := d.Info()
-			if statErr == nil && fileInfo.Size() > 5*1024*1024 { // Skip large files
-				fmt.Fprintf(os.Stderr, "Warning: Skipping potentially large file %s (>5MB)\n", path)
-				return nil
-			}
-			content, readErr := os.ReadFile(path)
-			if readErr != nil {
-				fmt.Fprintf(os.Stderr, "Warning: Error reading file %s: %v\n", path, readErr)
-				return nil
-			}
-			contextBuilder.WriteString(fmt.Sprintf("--- File: %s ---\n", absPath))
-			contextBuilder.Write(content)
+		var contextBuilder strings.Builder
+		for _, f := range files {
+			contextBuilder.WriteString(fmt.Sprintf("--- File: %s ---\n", f.path))
+			contextBuilder.Write(f.content)
 			contextBuilder.WriteString("\n\n")
-			filesCollected++
-			return nil
-		}) // End WalkDir func
-		contextBuilder.WriteString("Take on the persona of a distinguished software engineer.")
-
-		if walkErr != nil {
-			return fmt.Errorf("error during directory traversal of %q: %w", absTargetDir, walkErr)
 		}
+		contextBuilder.WriteString(footer)
+		filesCollected := len(files)
+
 		if filesCollected == 0 {
-			fmt.Fprintln(os.Stderr, "Warning: No relevant files found matching criteria.")
+			warnf("no-files", "No relevant files found matching criteria.\n")
 		} else {
-			fmt.Fprintf(os.Stderr, "Collected context from %d file(s).\n", filesCollected)
+			logf("Collected context from %d file(s).\n", filesCollected)
 		}
 
 		collectedContent := contextBuilder.String()
@@ -155,9 +128,9 @@ Filtering logic is the same as 'vibe show' default.`,
 		// --- 3. Conditional Action: Local vs SSH ---
 		if inSSH {
 			// --- SSH Behavior ---
-			fmt.Fprintln(os.Stderr, "\n---")
-			fmt.Fprintf(os.Stderr, "Attempting copy to local clipboard via OSC 52 sequence...\n")
-			fmt.Fprintf(os.Stderr, "(Requires a compatible terminal like iTerm2, Windows Terminal, Kitty)\n")
+			logln("\n---")
+			logf("Attempting copy to local clipboard via OSC 52 sequence...\n")
+			logf("(Requires a compatible terminal like iTerm2, Windows Terminal, Kitty)\n")
 
 			// Print the OSC 52 sequence to stdout. The terminal *might* intercept this.
 			// Don't print a newline after, as the sequence itself handles termination.
@@ -166,9 +139,9 @@ Filtering logic is the same as 'vibe show' default.`,
 			}
 
 			// Provide instructions and fallback plan via stderr
-			fmt.Fprintf(os.Stderr, "Check your local clipboard. If it worked, great!\n")
-			fmt.Fprintf(os.Stderr, "If not, your terminal may not support OSC 52. Manually copy the context below.\n")
-			fmt.Fprintln(os.Stderr, "--- Context for Manual Copy Starts Below ---")
+			logf("Check your local clipboard. If it worked, great!\n")
+			logf("If not, your terminal may not support OSC 52. Manually copy the context below.\n")
+			logln("--- Context for Manual Copy Starts Below ---")
 
 			// Print the collected content to stdout *as a fallback* for manual copying.
 			// This will appear in the terminal regardless of OSC 52 support.
@@ -176,36 +149,66 @@ Filtering logic is the same as 'vibe show' default.`,
 			fmt.Println("🌐 Gemini URL: ", geminiURL)
 
 		} else {
-			// --- Local Behavior (unchanged) ---
+			// --- Local Behavior (clip.exe/cmd.exe under WSL) ---
 			if collectedContent != "" {
-				err = clipboard.WriteAll(collectedContent)
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "Warning: Failed to copy context to local clipboard: %v\n", err)
+				err = copyToClipboard(collectedContent, inWSL)
+				if err != nil && inWSL {
+					warnf("clipboard", "Failed to copy context with clip.exe: %v\n", err)
+					logln("Falling back to OSC 52 copy (supported by Windows Terminal)...")
+					fmt.Print(osc52Copy(collectedContent))
+				} else if err != nil {
+					warnf("clipboard", "Failed to copy context to local clipboard: %v\n", err)
 				} else {
-					fmt.Fprintln(os.Stderr, "✅ Context copied to local clipboard!")
+					logln("✅ Context copied to local clipboard!")
 				}
 			} else {
-				fmt.Fprintln(os.Stderr, "No content gathered to copy to clipboard.")
+				logln("No content gathered to copy to clipboard.")
 			}
 
-			fmt.Fprintf(os.Stderr, "Attempting to open %s in your local browser...\n", geminiURL)
-			err = browser.OpenURL(geminiURL)
+			logf("Attempting to open %s in your local browser...\n", geminiURL)
+			err = openURL(geminiURL, inWSL)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Warning: Failed to open browser automatically: %v\n", err)
+				warnf("browser", "Failed to open browser automatically: %v\n", err)
 				fmt.Fprintf(os.Stderr, "Please open %s manually.\n", geminiURL)
 			} else {
-				fmt.Fprintln(os.Stderr, "✅ Browser opened (or attempted).")
+				logln("✅ Browser opened (or attempted).")
 			}
 
-			fmt.Fprintln(os.Stderr, "\n➡️ Please MANUALLY PASTE the copied context into the Gemini chat input (Ctrl+V or Cmd+V).")
-			fmt.Fprintln(os.Stderr, "---")
+			logln("\n➡️ Please MANUALLY PASTE the copied context into the Gemini chat input (Ctrl+V or Cmd+V).")
+			logln("---")
 		}
 
 		return nil
 	},
 }
 
+// geminiSkipDirs lists directories gemini never gathers context from
+var geminiSkipDirs = map[string]bool{".git": true, "node_modules": true, "vendor": true, "__pycache__": true, "venv": true, ".venv": true, "target": true, "build": true, "dist": true}
+
+// geminiSkipDir reports whether gemini should skip a directory.
+func geminiSkipDir(dirName string) bool {
+	return strings.HasPrefix(dirName, ".") || geminiSkipDirs[dirName]
+}
+
+// geminiIncludeFile applies the same file filtering as 'vibe show' default,
+// additionally dropping files without an extension.
+func geminiIncludeFile(fileName string) bool {
+	isHidden := strings.HasPrefix(fileName, ".")
+	isTestFile := strings.HasSuffix(fileName, "_test.go")
+	isModFile := fileName == "go.mod"
+	isSumFile := fileName == "go.sum"
+	isLicense := fileName == "LICENSE"
+	isMarkdown := strings.HasSuffix(strings.ToLower(fileName), ".md")
+	hasNoExtension := !strings.Contains(fileName, ".")
+	return !(isTestFile || isModFile || isSumFile || isLicense || isMarkdown || isHidden || hasNoExtension)
+}
+
 // --- Init Function ---
 func init() {
 	rootCmd.AddCommand(geminiCmd)
+	geminiCmd.Flags().BoolVar(&noPersona, "no-persona", false, "Don't append the persona line to the copied context")
+	geminiCmd.Flags().StringVar(&personaText, "persona", defaultPersona, "Persona line appended to the copied context")
+	geminiCmd.Flags().StringVar(&footerFile, "footer-file", "", "File whose content ends the copied context in place of the persona line")
+	geminiCmd.MarkFlagsMutuallyExclusive("no-persona", "persona", "footer-file")
+	addWalkFlags(geminiCmd)
 }
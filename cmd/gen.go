@@ -1,15 +1,14 @@
 package cmd
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
+	"unicode"
 
 	"github.com/charmbracelet/glamour"
 	"github.com/sashabaranov/go-openai"
@@ -17,11 +16,126 @@ import (
 )
 
 var raw bool
+var genHTMLFile string              // Flag for exporting the responses as standalone HTML
+var sharedKeyInterval time.Duration // Flag for spacing requests that share an API key
+var debugResponses string           // Flag for dumping raw provider responses ("-" = stderr, otherwise a directory)
+var mergePromptFile string          // Flag for a file replacing the default merge instructions
+var genProviderIDs []string         // Flag selecting which registered providers to query
+
+// Sampling flags applied to each provider's request body
+var (
+	genTemperature float64
+	genTopP        float64
+	genSeed        int
+)
+
+// samplingParams holds the sampling flags the user explicitly set.
+// Nil fields are omitted from request bodies.
+type samplingParams struct {
+	temperature *float64
+	topP        *float64
+	seed        *int
+}
+
+// applyTo adds the supported sampling parameters to a provider request body.
+// Providers that don't support seed simply don't get the field.
+func (p samplingParams) applyTo(body map[string]interface{}, supportsSeed bool) {
+	if p.temperature != nil {
+		body["temperature"] = *p.temperature
+	}
+	if p.topP != nil {
+		body["top_p"] = *p.topP
+	}
+	if p.seed != nil && supportsSeed {
+		body["seed"] = *p.seed
+	}
+}
+
+// providerResponse is a successful response from one provider.
+type providerResponse struct {
+	model string // Provider display name
+	resp  string
+}
+
+// providerResult is what a provider's goroutine reports back to gen.
+type providerResult struct {
+	providerResponse
+	err     error
+	elapsed time.Duration
+}
+
+// providerHeaderColors are the ANSI colors given to provider headers, by
+// position in genProviders.
+var providerHeaderColors = []string{"\033[36m", "\033[35m", "\033[33m", "\033[32m", "\033[34m"}
+
+// providerHeader formats a provider's response header with its elapsed time,
+// colored per provider when color is set.
+func providerHeader(name string, elapsed time.Duration, color bool) string {
+	header := fmt.Sprintf("%s Response (%.1fs)", name, elapsed.Seconds())
+	if !color {
+		return header
+	}
+	return providerColor(name) + header + "\033[0m"
+}
+
+// providerColor returns the ANSI codes that start the bold, per-provider
+// color of the provider called name.
+func providerColor(name string) string {
+	for i, p := range genProviders {
+		if p.Name() == name {
+			return "\033[1m" + providerHeaderColors[i%len(providerHeaderColors)]
+		}
+	}
+	return "\033[1m"
+}
 
 var genCmd = &cobra.Command{
 	Use:   "gen <prompt-file>",
 	Short: "Generate responses from multiple AI models",
-	Args:  cobra.ExactArgs(1),
+	Long: `Sends the prompt file to OpenAI, Gemini (via OpenRouter), and Claude in parallel,
+prints each response, then merges them with GPT-4o (skipped when only one
+provider succeeds). Use --providers to query only some of them, e.g.
+--providers openai,claude.
+
+Use --format to choose the output: markdown (the default, rendered for the
+terminal), text (the same document as plain Markdown; --raw is the older
+spelling) or json, one object with each provider's response, error and
+elapsed time plus the merged response, for scripts.
+
+Use --stream to watch the responses arrive instead of waiting for the slowest
+provider: each provider's response streams in live, a line at a time, labelled
+with the provider's name (and colored in a terminal). Once all have finished
+the merge runs as usual.
+
+Use --ollama-model to add a local model served by Ollama to the comparison,
+e.g. --ollama-model llama3.2. It needs no API key and talks to
+http://localhost:11434, or to $OLLAMA_HOST when set.
+
+Use --azure to send the OpenAI request and the merge to an Azure OpenAI
+deployment instead. It reads AZURE_OPENAI_API_KEY, and --azure-endpoint,
+--azure-deployment and --azure-api-version default to $AZURE_OPENAI_ENDPOINT,
+$AZURE_OPENAI_DEPLOYMENT and $AZURE_OPENAI_API_VERSION.
+
+Each response header shows how long that provider took and, in a terminal, is
+colored per provider; providers that failed are listed at the end.
+
+Sampling parameters are only sent when set, and only to providers that honor them:
+  --temperature   OpenAI, Gemini (OpenRouter), Claude, Ollama
+  --top-p         OpenAI, Gemini (OpenRouter), Claude, Ollama
+  --seed          Gemini (OpenRouter), Ollama
+
+Providers are queried in parallel, except that providers resolving to the same
+API key are sent one after another (spaced by --shared-key-interval) to avoid
+per-key rate limits.
+
+Use --merge-prompt-file to replace the default merge instructions (pick the best
+response or combine them) with your own, e.g. "pick the most concise answer" or
+"combine them into a decision matrix". The labelled responses are still appended.
+
+Use --debug-responses to print each provider's raw response body to stderr, or
+--debug-responses=DIR to save them as DIR/<provider>.json. This helps diagnose
+"no content found" errors when a provider changes its response schema.`,
+	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		promptFile := args[0]
 		prompt, err := os.ReadFile(promptFile)
@@ -29,462 +143,226 @@ var genCmd = &cobra.Command{
 			return fmt.Errorf("failed to read prompt file: %w", err)
 		}
 
-		var wg sync.WaitGroup
-		results := make(chan struct {
-			model string
-			resp  string
-			err   error
-		}, 3)
-
-		// OpenAI
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-
-			apiKey := os.Getenv("OPENAI_API_KEY")
-			if apiKey == "" {
-				results <- struct {
-					model string
-					resp  string
-					err   error
-				}{model: "OpenAI", err: fmt.Errorf("OPENAI_API_KEY environment variable not set")}
-				return
-			}
-
-			requestBody := map[string]interface{}{
-				"model": "gpt-4.1", // Or "gpt-4.1" if preferred and available
-				"input": string(prompt),
-				// Add other parameters like temperature, max_output_tokens if needed
-			}
-			requestBodyBytes, err := json.Marshal(requestBody)
-			if err != nil {
-				results <- struct {
-					model string
-					resp  string
-					err   error
-				}{model: "OpenAI", err: fmt.Errorf("failed to marshal request body: %w", err)}
-				return
-			}
-
-			req, err := http.NewRequest("POST", "https://api.openai.com/v1/responses", bytes.NewBuffer(requestBodyBytes))
-			if err != nil {
-				results <- struct {
-					model string
-					resp  string
-					err   error
-				}{model: "OpenAI", err: fmt.Errorf("failed to create request: %w", err)}
-				return
-			}
-
-			req.Header.Set("Authorization", "Bearer "+apiKey)
-			req.Header.Set("Content-Type", "application/json")
-
-			client := &http.Client{Timeout: 20 * time.Minute} // Reuse timeout logic
-			resp, err := client.Do(req)
-			if err != nil {
-				results <- struct {
-					model string
-					resp  string
-					err   error
-				}{model: "OpenAI", err: fmt.Errorf("failed to send request: %w", err)}
-				return
-			}
-			defer resp.Body.Close()
-
-			responseBodyBytes, err := io.ReadAll(resp.Body)
-			if err != nil {
-				results <- struct {
-					model string
-					resp  string
-					err   error
-				}{model: "OpenAI", err: fmt.Errorf("failed to read response body: %w", err)}
-				return
-			}
-
-			if resp.StatusCode != http.StatusOK {
-				results <- struct {
-					model string
-					resp  string
-					err   error
-				}{model: "OpenAI", err: fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(responseBodyBytes))}
-				return
-			}
-
-			// Define a struct to parse the relevant part of the response
-			var responseBody struct {
-				Output []struct {
-					Content []struct {
-						Text string `json:"text"`
-					} `json:"content"`
-				} `json:"output"`
-				Error *struct { // Check for API errors in the response body
-					Message string `json:"message"`
-					Type    string `json:"type"`
-					Code    string `json:"code"` // Code can be string or int
-				} `json:"error"`
-			}
-
-			err = json.Unmarshal(responseBodyBytes, &responseBody)
-			if err != nil {
-				results <- struct {
-					model string
-					resp  string
-					err   error
-				}{model: "OpenAI", err: fmt.Errorf("failed to unmarshal response body: %w", err)}
-				return
-			}
-
-			// Check for errors returned in the JSON body
-			if responseBody.Error != nil {
-				results <- struct {
-					model string
-					resp  string
-					err   error
-				}{model: "OpenAI", err: fmt.Errorf("OpenAI API error (%s): %s", responseBody.Error.Code, responseBody.Error.Message)}
-				return
-			}
-
-			// Extract the text content
-			var responseText string
-			if len(responseBody.Output) > 0 && len(responseBody.Output[0].Content) > 0 {
-				responseText = responseBody.Output[0].Content[0].Text
-			} else {
-				results <- struct {
-					model string
-					resp  string
-					err   error
-				}{model: "OpenAI", err: fmt.Errorf("no content found in response structure")}
-				return
-			}
-
-			results <- struct {
-				model string
-				resp  string
-				err   error
-			}{
-				model: "OpenAI",
-				resp:  responseText,
-				err:   nil, // Explicitly nil on success
-			}
-		}()
-
-		// Gemini
-		// Gemini via OpenRouter
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-
-			apiKey := os.Getenv("OPENROUTER_API_KEY")
-			if apiKey == "" {
-				results <- struct {
-					model string
-					resp  string
-					err   error
-				}{model: "Gemini (OpenRouter)", err: fmt.Errorf("OPENROUTER_API_KEY environment variable not set")}
-				return
-			}
-
-			requestBody := map[string]interface{}{
-				"model": "google/gemini-2.5-pro-preview-03-25", // OpenRouter model name
-				"messages": []map[string]any{
-					{
-						"role": "user",
-						"content": []map[string]any{
-							{"type": "text", "text": string(prompt)},
-						},
-					},
-				},
-			}
-			requestBodyBytes, err := json.Marshal(requestBody)
-			if err != nil {
-				results <- struct {
-					model string
-					resp  string
-					err   error
-				}{model: "Gemini (OpenRouter)", err: fmt.Errorf("failed to marshal request body: %w", err)}
-				return
-			}
-
-			req, err := http.NewRequest("POST", "https://openrouter.ai/api/v1/chat/completions", bytes.NewBuffer(requestBodyBytes))
-			if err != nil {
-				results <- struct {
-					model string
-					resp  string
-					err   error
-				}{model: "Gemini (OpenRouter)", err: fmt.Errorf("failed to create request: %w", err)}
-				return
-			}
-
-			req.Header.Set("Authorization", "Bearer "+apiKey)
-			req.Header.Set("Content-Type", "application/json")
-			// Optional but recommended headers for OpenRouter
-			// req.Header.Set("HTTP-Referer", "YOUR_SITE_URL") // Replace with your site URL
-			// req.Header.Set("X-Title", "YOUR_APP_NAME") // Replace with your app name
-
-			client := &http.Client{Timeout: 20 * time.Minute}
-			resp, err := client.Do(req)
-			if err != nil {
-				results <- struct {
-					model string
-					resp  string
-					err   error
-				}{model: "Gemini (OpenRouter)", err: fmt.Errorf("failed to send request: %w", err)}
-				return
-			}
-			defer resp.Body.Close()
-
-			responseBodyBytes, err := io.ReadAll(resp.Body)
-			if err != nil {
-				results <- struct {
-					model string
-					resp  string
-					err   error
-				}{model: "Gemini (OpenRouter)", err: fmt.Errorf("failed to read response body: %w", err)}
-				return
-			}
-
-			if resp.StatusCode != http.StatusOK {
-				results <- struct {
-					model string
-					resp  string
-					err   error
-				}{model: "Gemini (OpenRouter)", err: fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(responseBodyBytes))}
-				return
-			}
-
-			// Parse the OpenRouter response structure
-			var responseBody struct {
-				Choices []struct {
-					Message struct {
-						Content string `json:"content"`
-					} `json:"message"`
-				} `json:"choices"`
-				Error *struct { // Check for API errors in the response body
-					Message string `json:"message"`
-					Type    string `json:"type"`
-					Code    int64  `json:"code"`
-				} `json:"error"`
-			}
-			err = json.Unmarshal(responseBodyBytes, &responseBody)
+		if raw && !cmd.Flags().Changed("format") {
+			genFormat = formatText // --raw predates --format
+		}
+		var renderer *glamour.TermRenderer // Left nil with --format text
+		if genFormat == formatMarkdown {
+			renderer, err = newMarkdownRenderer("dark")
 			if err != nil {
-				results <- struct {
-					model string
-					resp  string
-					err   error
-				}{model: "Gemini (OpenRouter)", err: fmt.Errorf("failed to unmarshal response body: %w", err)}
-				return
-			}
-
-			// Check for errors returned in the JSON body
-			if responseBody.Error != nil {
-				results <- struct {
-					model string
-					resp  string
-					err   error
-				}{model: "Gemini (OpenRouter)", err: fmt.Errorf("OpenRouter API error (%d): %s", responseBody.Error.Code, responseBody.Error.Message)}
-				return
-			}
-
-			if len(responseBody.Choices) == 0 || responseBody.Choices[0].Message.Content == "" {
-				results <- struct {
-					model string
-					resp  string
-					err   error
-				}{model: "Gemini (OpenRouter)", err: fmt.Errorf("no content found in response")}
-				return
-			}
-
-			results <- struct {
-				model string
-				resp  string
-				err   error
-			}{
-				model: "Gemini (OpenRouter)",
-				resp:  responseBody.Choices[0].Message.Content,
-				err:   nil,
-			}
-		}()
-
-		// Claude
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-
-			apiKey := os.Getenv("ANTHROPIC_API_KEY")
-			if apiKey == "" {
-				results <- struct {
-					model string
-					resp  string
-					err   error
-				}{model: "Claude", err: fmt.Errorf("ANTHROPIC_API_KEY environment variable not set")}
-				return
+				return err
 			}
+		}
 
-			requestBody := map[string]interface{}{
-				"model":      "claude-3-5-sonnet-20241022", // Or use the specific model from curl example if needed
-				"max_tokens": 2048,
-				"messages": []map[string]string{
-					{"role": "user", "content": string(prompt)},
-				},
-			}
-			requestBodyBytes, err := json.Marshal(requestBody)
+		mergeInstructions := defaultMergeInstructions
+		if mergePromptFile != "" {
+			custom, err := os.ReadFile(mergePromptFile)
 			if err != nil {
-				results <- struct {
-					model string
-					resp  string
-					err   error
-				}{model: "Claude", err: fmt.Errorf("failed to marshal request body: %w", err)}
-				return
+				return fmt.Errorf("failed to read merge prompt file: %w", err)
 			}
+			mergeInstructions = string(custom)
+		}
 
-			req, err := http.NewRequest("POST", "https://api.anthropic.com/v1/messages", bytes.NewBuffer(requestBodyBytes))
-			if err != nil {
-				results <- struct {
-					model string
-					resp  string
-					err   error
-				}{model: "Claude", err: fmt.Errorf("failed to create request: %w", err)}
-				return
-			}
+		var sampling samplingParams
+		if cmd.Flags().Changed("temperature") {
+			sampling.temperature = &genTemperature
+		}
+		if cmd.Flags().Changed("top-p") {
+			sampling.topP = &genTopP
+		}
+		if cmd.Flags().Changed("seed") {
+			sampling.seed = &genSeed
+		}
 
-			req.Header.Set("x-api-key", apiKey)
-			req.Header.Set("anthropic-version", "2023-06-01")
-			req.Header.Set("content-type", "application/json")
+		if genStream && genFormat == formatJSON {
+			return fmt.Errorf("--stream prints the responses as they arrive; it can't be combined with --format json")
+		}
 
-			client := &http.Client{Timeout: 20 * time.Minute}
-			resp, err := client.Do(req)
-			if err != nil {
-				results <- struct {
-					model string
-					resp  string
-					err   error
-				}{model: "Claude", err: fmt.Errorf("failed to send request: %w", err)}
-				return
+		providerList := genProviderIDs
+		if !cmd.Flags().Changed("providers") {
+			providerList = defaultProviderIDs()
+		}
+		selected, err := selectProviders(providerList)
+		if err != nil {
+			return err
+		}
+		for _, p := range selected {
+			if p.ID() == "ollama" && ollamaModel == "" {
+				return fmt.Errorf("--providers ollama needs --ollama-model to name the local model, e.g. --ollama-model llama3.2")
 			}
-			defer resp.Body.Close()
+		}
 
-			responseBodyBytes, err := io.ReadAll(resp.Body)
+		// --azure swaps OpenAI for the Azure deployment, which also does the merge
+		mergeConfig := openai.DefaultConfig(os.Getenv("OPENAI_API_KEY"))
+		mergeConfig.HTTPClient = newHTTPClient(0)
+		mergeClient := openai.NewClientWithConfig(mergeConfig)
+		if useAzure {
+			azure, err := resolveAzureConfig()
 			if err != nil {
-				results <- struct {
-					model string
-					resp  string
-					err   error
-				}{model: "Claude", err: fmt.Errorf("failed to read response body: %w", err)}
-				return
+				return err
 			}
-
-			if resp.StatusCode != http.StatusOK {
-				results <- struct {
-					model string
-					resp  string
-					err   error
-				}{model: "Claude", err: fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(responseBodyBytes))}
-				return
+			for i, p := range selected {
+				if p.ID() == "openai" {
+					selected[i] = azureOpenAIProvider{cfg: azure}
+				}
 			}
+			mergeClient = azure.openAIClient()
+		}
 
-			var responseBody struct {
-				Content []struct {
-					Text string `json:"text"`
-				} `json:"content"`
-			}
-			err = json.Unmarshal(responseBodyBytes, &responseBody)
-			if err != nil {
-				results <- struct {
-					model string
-					resp  string
-					err   error
-				}{model: "Claude", err: fmt.Errorf("failed to unmarshal response body: %w", err)}
-				return
-			}
+		// Providers that share an API key are sent one at a time
+		var keys []string
+		for _, p := range selected {
+			keys = append(keys, os.Getenv(p.APIKeyEnv()))
+		}
+		genLimiter = newKeyLimiter(keys, sharedKeyInterval)
+		genSampling = sampling
 
-			if len(responseBody.Content) == 0 {
-				results <- struct {
-					model string
-					resp  string
-					err   error
-				}{model: "Claude", err: fmt.Errorf("no content found in response")}
-				return
-			}
+		// Headers are colored outside the Markdown when rendering to a terminal
+		colorHeaders := renderer != nil && stdoutIsTerminal() && os.Getenv("NO_COLOR") == ""
 
-			results <- struct {
-				model string
-				resp  string
-				err   error
-			}{
-				model: "Claude",
-				resp:  responseBody.Content[0].Text,
-				err:   nil,
+		results := make(chan providerResult, len(selected))
+		if genStream {
+			// The responses are printed as they stream in, so the loop below only collects them
+			for _, result := range streamGenProviders(selected, string(prompt), stdoutIsTerminal() && os.Getenv("NO_COLOR") == "") {
+				results <- result
 			}
-		}()
-
-		go func() {
-			wg.Wait()
 			close(results)
-		}()
+		} else {
+			var wg sync.WaitGroup
+			for _, p := range selected {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					start := time.Now()
+					resp, err := p.Complete(context.Background(), string(prompt))
+					results <- providerResult{providerResponse: providerResponse{model: p.Name(), resp: resp}, err: err, elapsed: time.Since(start)}
+				}()
+			}
+
+			go func() {
+				wg.Wait()
+				close(results)
+			}()
+		}
 
-		var successfulResponses []struct {
-			model string
-			resp  string
+		if genFormat == formatJSON {
+			return printGenJSON(results, mergeClient, mergeInstructions, promptFile, string(prompt))
 		}
 
+		var successfulResponses []providerResponse
+		var failedProviders []string
 		for result := range results {
 			if result.err != nil {
-				fmt.Printf("%s error: %v\n", result.model, result.err)
+				if !genStream {
+					fmt.Printf("%s error: %v\n", result.model, result.err)
+				}
+				failedProviders = append(failedProviders, result.model)
 				continue
 			}
-			md := fmt.Sprintf("### %s Response\n\n```\n%s\n```", result.model, result.resp)
-
-			if raw {
-				fmt.Println(md)
-			} else {
-				out, err := glamour.Render(md, "dark")
-				if err != nil {
-					fmt.Println(md) // fallback to raw markdown
-				} else {
-					fmt.Println(out)
-				}
+			switch {
+			case genStream:
+				// Already shown as it streamed
+			case colorHeaders:
+				fmt.Println(providerHeader(result.model, result.elapsed, true))
+				fmt.Println(renderMarkdown(renderer, fmt.Sprintf("```\n%s\n```", result.resp)))
+			default:
+				md := fmt.Sprintf("### %s\n\n```\n%s\n```", providerHeader(result.model, result.elapsed, false), result.resp)
+				fmt.Println(renderMarkdown(renderer, md))
 			}
 
-			successfulResponses = append(successfulResponses, struct {
-				model string
-				resp  string
-			}{model: result.model, resp: result.resp})
+			successfulResponses = append(successfulResponses, result.providerResponse)
 		}
 
-		if len(successfulResponses) > 0 {
+		switch {
+		case len(successfulResponses) == 1:
+			// Nothing to merge; the single response above is the answer
+			logf("\nOnly %s responded; skipping the merge step.\n", successfulResponses[0].model)
+			recordGenHistory(string(prompt), "", successfulResponses)
+			if genHTMLFile != "" {
+				if exportErr := writeHTMLExport(genHTMLFile, "vibe gen: "+promptFile, genExportMarkdown("", successfulResponses)); exportErr != nil {
+					warnf("export", "%v\n", exportErr)
+				}
+			}
+		case len(successfulResponses) > 1:
 			fmt.Println("\n=== Merging Responses ===")
-			mergeClient := openai.NewClient(os.Getenv("OPENAI_API_KEY"))
-			mergedResponse, err := mergeResponses(mergeClient, successfulResponses)
+			mergedResponse, err := mergeResponses(mergeClient, mergeInstructions, successfulResponses)
+			if genHTMLFile != "" {
+				if exportErr := writeHTMLExport(genHTMLFile, "vibe gen: "+promptFile, genExportMarkdown(mergedResponse, successfulResponses)); exportErr != nil {
+					warnf("export", "%v\n", exportErr)
+				}
+			}
+			recordGenHistory(string(prompt), mergedResponse, successfulResponses)
 			if err != nil {
 				fmt.Printf("Error merging responses: %v\n", err)
 			} else {
 				mergedMD := fmt.Sprintf("## Merged Response\n\n```\n%s\n```", mergedResponse)
-				if raw {
-					fmt.Println(mergedMD)
-				} else {
-					out, err := glamour.Render(mergedMD, "dark")
-					if err != nil {
-						fmt.Println(mergedMD)
-					} else {
-						fmt.Println(out)
-					}
-				}
+				fmt.Println(renderMarkdown(renderer, mergedMD))
 			}
-		} else {
+		default:
 			fmt.Println("\nNo successful responses to merge.")
 		}
 
+		if len(failedProviders) > 0 {
+			fmt.Printf("\n%d of %d provider(s) failed: %s\n", len(failedProviders), len(selected), strings.Join(failedProviders, ", "))
+		}
+
 		return nil
 	},
 }
 
-func mergeResponses(client *openai.Client, responses []struct {
-	model string
-	resp  string
-}) (string, error) {
-	prompt := "Below are responses from different AI models to the same prompt. Please analyze these responses and provide either:\n" +
-		"1. The best single response if one clearly stands out, or\n" +
-		"2. A merged response that combines the unique insights and important points from all responses.\n\n"
+// genJSONResponse is one provider's entry in gen --format json output.
+type genJSONResponse struct {
+	Provider       string  `json:"provider"`
+	Response       string  `json:"response,omitempty"`
+	Error          string  `json:"error,omitempty"`
+	ElapsedSeconds float64 `json:"elapsed_seconds"`
+}
+
+// printGenJSON collects every provider's result, merges the successful ones
+// as usual (when there are at least two) and prints it all as one JSON object.
+func printGenJSON(results <-chan providerResult, mergeClient *openai.Client, mergeInstructions, promptFile, prompt string) error {
+	out := struct {
+		Responses  []genJSONResponse `json:"responses"`
+		Merged     string            `json:"merged,omitempty"`
+		MergeError string            `json:"merge_error,omitempty"`
+	}{Responses: []genJSONResponse{}}
+	var successful []providerResponse
+	for result := range results {
+		entry := genJSONResponse{Provider: result.model, ElapsedSeconds: result.elapsed.Seconds()}
+		if result.err != nil {
+			entry.Error = result.err.Error()
+		} else {
+			entry.Response = result.resp
+			successful = append(successful, result.providerResponse)
+		}
+		out.Responses = append(out.Responses, entry)
+	}
+	if len(successful) > 1 {
+		merged, err := mergeResponses(mergeClient, mergeInstructions, successful)
+		if err != nil {
+			out.MergeError = err.Error()
+		}
+		out.Merged = merged
+	}
+	recordGenHistory(prompt, out.Merged, successful)
+	if genHTMLFile != "" && len(successful) > 0 {
+		if err := writeHTMLExport(genHTMLFile, "vibe gen: "+promptFile, genExportMarkdown(out.Merged, successful)); err != nil {
+			warnf("export", "%v\n", err)
+		}
+	}
+	return printJSON(out)
+}
+
+// defaultMergeInstructions tells the merge model what to do with the responses
+// unless --merge-prompt-file replaces it.
+const defaultMergeInstructions = "Below are responses from different AI models to the same prompt. Please analyze these responses and provide either:\n" +
+	"1. The best single response if one clearly stands out, or\n" +
+	"2. A merged response that combines the unique insights and important points from all responses."
+
+// mergeResponses asks GPT-4o to synthesize the responses, following instructions
+// and then the labelled responses.
+func mergeResponses(client *openai.Client, instructions string, responses []providerResponse) (string, error) {
+	prompt := strings.TrimRight(instructions, "\n") + "\n\n"
 
 	for _, resp := range responses {
 		prompt += fmt.Sprintf("=== %s Response ===\n%s\n\n", resp.model, resp.resp)
@@ -503,7 +381,71 @@ func mergeResponses(client *openai.Client, responses []struct {
 	return resp.Choices[0].Message.Content, nil
 }
 
+// genExportMarkdown assembles the merged response followed by one section per
+// provider, for exporting gen output as a single document.
+func genExportMarkdown(merged string, responses []providerResponse) string {
+	var b strings.Builder
+	if merged != "" {
+		fmt.Fprintf(&b, "## Merged Response\n\n%s\n\n", merged)
+	}
+	for _, r := range responses {
+		fmt.Fprintf(&b, "## %s Response\n\n%s\n\n", r.model, r.resp)
+	}
+	return b.String()
+}
+
+// debugDumpMu keeps raw responses dumped to stderr from interleaving.
+var debugDumpMu sync.Mutex
+
+// dumpRawResponse writes a provider's raw response body to stderr or to
+// --debug-responses DIR, if the flag was given.
+func dumpRawResponse(provider string, body []byte) {
+	if debugResponses == "" {
+		return
+	}
+	if debugResponses == "-" {
+		debugDumpMu.Lock()
+		defer debugDumpMu.Unlock()
+		fmt.Fprintf(os.Stderr, "--- Raw %s response ---\n%s\n--- End raw %s response ---\n", provider, body, provider)
+		return
+	}
+
+	name := strings.Trim(strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return '-'
+	}, provider), "-")
+	name = strings.ReplaceAll(name, "--", "-") + ".json"
+	if err := os.MkdirAll(debugResponses, 0o755); err != nil {
+		warnf("debug-responses", "Failed to create --debug-responses directory: %v\n", err)
+		return
+	}
+	path := filepath.Join(debugResponses, name)
+	if err := os.WriteFile(path, body, 0o644); err != nil {
+		warnf("debug-responses", "Failed to save raw %s response: %v\n", provider, err)
+		return
+	}
+	logf("Saved raw %s response to %s\n", provider, path)
+}
+
 func init() {
 	rootCmd.AddCommand(genCmd)
 	genCmd.Flags().BoolVarP(&raw, "raw", "r", false, "Print raw markdown output without formatting")
+	genCmd.Flags().MarkDeprecated("raw", "use --format text instead")
+	genCmd.Flags().Var(&genFormat, "format", "Output format: markdown (rendered), text (plain Markdown) or json")
+	genCmd.Flags().BoolVar(&genStream, "stream", false, "Stream each provider's response live, labelled by provider, before merging")
+	genCmd.MarkFlagsMutuallyExclusive("raw", "format")
+	genCmd.Flags().StringVar(&genHTMLFile, "html", "", "Also export the merged response and each provider's response as a standalone HTML file")
+	genCmd.Flags().BoolVar(&noHistory, "no-history", false, "Leave this run out of the history log (see vibe history)")
+	genCmd.Flags().DurationVar(&sharedKeyInterval, "shared-key-interval", 0, "Minimum delay between requests from providers that share an API key")
+	genCmd.Flags().Float64Var(&genTemperature, "temperature", 0, "Sampling temperature sent to each provider (omitted unless set)")
+	genCmd.Flags().Float64Var(&genTopP, "top-p", 0, "Nucleus sampling top_p sent to each provider (omitted unless set)")
+	genCmd.Flags().StringSliceVar(&genProviderIDs, "providers", defaultProviderIDs(), "Comma-separated providers to query (ollama is added by default with --ollama-model)")
+	genCmd.Flags().StringVar(&ollamaModel, "ollama-model", "", "Local Ollama model to query as well, e.g. llama3.2 (server from $OLLAMA_HOST, default http://localhost:11434)")
+	genCmd.Flags().StringVar(&mergePromptFile, "merge-prompt-file", "", "File whose contents replace the default instructions for merging the responses")
+	genCmd.Flags().StringVar(&debugResponses, "debug-responses", "", "Print each provider's raw response body to stderr, or save them with --debug-responses=DIR")
+	genCmd.Flags().Lookup("debug-responses").NoOptDefVal = "-"
+	addAzureFlags(genCmd)
+	genCmd.Flags().IntVar(&genSeed, "seed", 0, "Sampling seed for reproducible output (only honored by OpenRouter and Ollama)")
 }
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+var genStream bool // Flag to stream each provider's response as it arrives
+
+// genStreamLine is a complete line of one provider's streamed response, or
+// (with done set) the end of it.
+type genStreamLine struct {
+	provider int // Index into the selected providers
+	text     string
+	done     bool
+	err      error
+	elapsed  time.Duration
+}
+
+// streamGenProviders queries the providers concurrently with Stream, printing
+// each complete line as it arrives labelled with its provider (colored when
+// color is set), and returns their results once all have finished.
+func streamGenProviders(selected []Provider, prompt string, color bool) []providerResult {
+	lines := make(chan genStreamLine)
+	results := make([]providerResult, len(selected))
+	var wg sync.WaitGroup
+	for i, p := range selected {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			var pending strings.Builder
+			resp, err := p.Stream(context.Background(), prompt, func(delta string) {
+				pending.WriteString(delta)
+				text := pending.String()
+				for {
+					nl := strings.IndexByte(text, '\n')
+					if nl < 0 {
+						break
+					}
+					lines <- genStreamLine{provider: i, text: text[:nl]}
+					text = text[nl+1:]
+				}
+				pending.Reset()
+				pending.WriteString(text)
+			})
+			if pending.Len() > 0 {
+				lines <- genStreamLine{provider: i, text: pending.String()}
+			}
+			elapsed := time.Since(start)
+			results[i] = providerResult{providerResponse: providerResponse{model: p.Name(), resp: resp}, err: err, elapsed: elapsed}
+			lines <- genStreamLine{provider: i, done: true, err: err, elapsed: elapsed}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(lines)
+	}()
+
+	labelWidth := 0
+	for _, p := range selected {
+		labelWidth = max(labelWidth, len(p.Name()))
+	}
+	for l := range lines {
+		name := selected[l.provider].Name()
+		label := fmt.Sprintf("[%-*s]", labelWidth, name)
+		if color {
+			label = providerColor(name) + label + "\033[0m"
+		}
+		switch {
+		case l.done && l.err != nil:
+			fmt.Printf("%s (failed after %.1fs: %v)\n", label, l.elapsed.Seconds(), l.err)
+		case l.done:
+			fmt.Printf("%s (done in %.1fs)\n", label, l.elapsed.Seconds())
+		default:
+			fmt.Printf("%s %s\n", label, l.text)
+		}
+	}
+	return results
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// --- Variables for git diff context flags ---
+var (
+	contextFromDiff bool   // Flag to use a git diff as the context instead of the whole tree
+	diffBase        string // Flag for the ref the diff starts from (default: main or master)
+	diffHead        string // Flag for the ref the diff ends at
+	diffWithFiles   bool   // Flag to also include the full content of the changed files
+)
+
+// runGit runs git in dir and returns its stdout, with git's stderr in the
+// error if it fails.
+func runGit(dir string, args ...string) (string, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return "", fmt.Errorf("git not found on PATH: %w", err)
+	}
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("git %s: %s", strings.Join(args, " "), strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("git %s: %w", strings.Join(args, " "), err)
+	}
+	return string(out), nil
+}
+
+// defaultBaseRef picks the branch a change is usually compared against:
+// main or master, locally or on origin.
+func defaultBaseRef(dir string) (string, error) {
+	for _, ref := range []string{"main", "master", "origin/main", "origin/master"} {
+		if _, err := runGit(dir, "rev-parse", "--verify", "--quiet", ref+"^{commit}"); err == nil {
+			return ref, nil
+		}
+	}
+	return "", fmt.Errorf("no main or master branch found; pass --base")
+}
+
+// gitDiffContext returns the context for --context-from-git-diff: the diff of
+// base...head under root (the changes on head since it forked from base) and,
+// with --diff-with-files, the current content of each changed file.
+func gitDiffContext(root string) ([]gatheredFile, error) {
+	base := diffBase
+	if base == "" {
+		var err error
+		if base, err = defaultBaseRef(root); err != nil {
+			return nil, err
+		}
+	}
+	revs := base + "..." + diffHead
+	diff, err := runGit(root, "diff", "--relative", revs)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(diff) == "" {
+		return nil, fmt.Errorf("git diff %s is empty under %s", revs, root)
+	}
+	logf("Using git diff %s as the context.\n", revs)
+	files := []gatheredFile{{path: "git diff " + revs, content: []byte(diff)}}
+	if !diffWithFiles {
+		return files, nil
+	}
+
+	// -z keeps names with spaces or special characters whole and unquoted
+	names, err := runGit(root, "diff", "--relative", "--name-only", "-z", "--diff-filter=d", revs)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range strings.Split(names, "\x00") {
+		if name == "" {
+			continue
+		}
+		path := filepath.Join(root, filepath.FromSlash(name))
+		content, err := os.ReadFile(path)
+		if err != nil {
+			warnf("unreadable", "Error reading changed file %s: %v\n", path, err)
+			continue
+		}
+		files = append(files, gatheredFile{path: path, content: content})
+	}
+	logf("Added %d changed file(s).\n", len(files)-1)
+	return files, nil
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// gitInit makes root a repository with a single commit on main.
+func gitInit(t *testing.T, root string) {
+	t.Helper()
+	git(t, root, "init", "-q", "-b", "main")
+	git(t, root, "add", "-A")
+	git(t, root, "commit", "-q", "-m", "initial")
+}
+
+// git runs a git command in dir, failing the test if it fails.
+func git(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	c := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	if out, err := c.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+}
+
+func TestGitDiffContextUnusualNames(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found on PATH")
+	}
+	names := []string{"my file.go", "dé.go", "plain.go"}
+	files := map[string]string{}
+	for _, name := range names {
+		files[name] = "package p\n"
+	}
+	root := writeTree(t, files)
+	gitInit(t, root)
+	git(t, root, "checkout", "-q", "-b", "feature")
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(root, name), []byte("package p\n\n// changed\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git(t, root, "commit", "-q", "-a", "-m", "change")
+
+	diffBase, diffHead, diffWithFiles = "main", "HEAD", true
+	t.Cleanup(func() { diffBase, diffHead, diffWithFiles = "", "HEAD", false })
+
+	got, err := gitDiffContext(root)
+	if err != nil {
+		t.Fatalf("gitDiffContext: %v", err)
+	}
+	if len(got) == 0 {
+		t.Fatal("gitDiffContext returned no files")
+	}
+	gotNames := gatheredNames(got[1:]) // got[0] is the diff itself
+	want := []string{"dé.go", "my file.go", "plain.go"}
+	if !slices.Equal(gotNames, want) {
+		t.Errorf("changed files = %v, want %v", gotNames, want)
+	}
+}
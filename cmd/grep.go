@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+)
+
+var (
+	showGrep       string // Flag for a regular expression the shown files' content must match
+	showIgnoreCase bool   // Flag to match --grep case-insensitively
+)
+
+// compileShowGrep compiles --grep, honoring -i. It returns nil without --grep.
+func compileShowGrep() (*regexp.Regexp, error) {
+	if showGrep == "" {
+		if showIgnoreCase {
+			return nil, fmt.Errorf("-i only applies to --grep")
+		}
+		return nil, nil
+	}
+	flags := "(?m)" // ^ and $ match at line boundaries, as in grep
+	if showIgnoreCase {
+		flags = "(?mi)"
+	}
+	pattern := flags + showGrep
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --grep pattern: %w", err)
+	}
+	return re, nil
+}
+
+// grepFiles keeps the files whose content matches re.
+func grepFiles(files []gatheredFile, re *regexp.Regexp) []gatheredFile {
+	var matched []gatheredFile
+	for _, f := range files {
+		if re.Match(f.content) {
+			matched = append(matched, f)
+		}
+	}
+	verbosef("%d of %d file(s) match --grep %s\n", len(matched), len(files), showGrep)
+	return matched
+}
+
+// highlightMatches colors each match of re in content for the terminal.
+func highlightMatches(content []byte, re *regexp.Regexp) []byte {
+	return re.ReplaceAllFunc(content, func(m []byte) []byte {
+		if len(m) == 0 {
+			return m
+		}
+		return []byte("\033[1;31m" + string(m) + "\033[0m")
+	})
+}
+
+// highlightGrep reports whether show should highlight --grep matches: only in
+// plain text written to a terminal, and not with NO_COLOR.
+func highlightGrep() bool {
+	return showFormat == formatText && stdoutIsTerminal() && os.Getenv("NO_COLOR") == ""
+}
@@ -0,0 +1,294 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+)
+
+// --- Variables for history flags ---
+var (
+	noHistory    bool // Flag to leave this request out of the history log
+	historyLimit int  // Flag for the number of entries vibe history lists
+	historyFull  bool // Flag to print each entry's stored response
+)
+
+// historyResponseLimit caps the bytes of each response kept in the history.
+const historyResponseLimit = 2000
+
+// historyEntry is one line of the history log.
+type historyEntry struct {
+	Time             time.Time `json:"time"`
+	Command          string    `json:"command"` // "code" or "gen"
+	Model            string    `json:"model"`   // For gen, the providers that answered
+	Prompt           string    `json:"prompt"`
+	Files            int       `json:"files"` // Files sent as context
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	Estimated        bool      `json:"tokens_estimated,omitempty"` // No usage was reported, so the tokens are estimated from lengths
+	Cached           bool      `json:"cached,omitempty"`
+	Response         string    `json:"response"` // Truncated to historyResponseLimit
+}
+
+// historyPath returns the history log's path: $XDG_DATA_HOME/vibe/history.jsonl,
+// or ~/.local/share/vibe/history.jsonl.
+func historyPath() (string, error) {
+	base := os.Getenv("XDG_DATA_HOME")
+	if base == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to locate home directory: %w", err)
+		}
+		base = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(base, "vibe", "history.jsonl"), nil
+}
+
+// historyEnabled reports whether requests are logged: config.json opts in
+// with "history": true, and --no-history opts a single request out.
+func historyEnabled() bool {
+	if noHistory {
+		return false
+	}
+	cfg, err := loadConfig()
+	return err == nil && cfg.History
+}
+
+// recordHistory appends e to the history log when it is enabled. promptBytes
+// is the size of everything sent, for estimating the tokens when e has no
+// usage. Secrets are redacted and the response truncated before writing, and
+// failures only warn, since the request itself succeeded.
+func recordHistory(e historyEntry, promptBytes int) {
+	if !historyEnabled() {
+		return
+	}
+	if e.PromptTokens == 0 && e.CompletionTokens == 0 {
+		e.PromptTokens = estimateTokens(promptBytes)
+		e.CompletionTokens = estimateTokens(len(e.Response))
+		e.Estimated = true
+	}
+	e.Time = time.Now()
+	e.Prompt = redactSecrets(e.Prompt)
+	e.Response = truncateHistory(redactSecrets(e.Response), historyResponseLimit, "... [truncated]")
+
+	if err := appendHistory(e); err != nil {
+		warnf("history", "Failed to record history: %v\n", err)
+	}
+}
+
+// appendHistory writes e as a line of the history log, which is private to
+// the user since prompts can be sensitive.
+func appendHistory(e historyEntry) error {
+	path, err := historyPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// recordGenHistory logs a gen run: the merged response if there was one,
+// otherwise the providers' responses.
+func recordGenHistory(prompt, merged string, responses []providerResponse) {
+	if len(responses) == 0 {
+		return
+	}
+	var names []string
+	for _, r := range responses {
+		names = append(names, r.model)
+	}
+	response := merged
+	if response == "" {
+		response = genExportMarkdown("", responses)
+	}
+	recordHistory(historyEntry{Command: "gen", Model: strings.Join(names, ", "), Prompt: prompt, Response: response}, len(prompt))
+}
+
+// truncateHistory cuts s to n bytes, on a rune boundary, ending it with marker
+// if anything was cut.
+func truncateHistory(s string, n int, marker string) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + marker
+}
+
+// secretPatterns match common credential formats that shouldn't be written to
+// the history log.
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`),
+	regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{16,}`),                 // OpenAI, OpenRouter and Anthropic keys
+	regexp.MustCompile(`\bAKIA[0-9A-Z]{16}\b`),                    // AWS access key IDs
+	regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{20,}`),            // GitHub tokens
+	regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`),          // Slack tokens
+	regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}`),                 // Google API keys
+	regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9._~+/-]{16,}=*`), // Authorization headers
+}
+
+// redactSecrets replaces the values of the API keys vibe uses, and anything
+// matching secretPatterns, with [REDACTED].
+func redactSecrets(s string) string {
+	envVars := []string{apiKeyEnvVar, azureAPIKeyEnvVar}
+	for _, p := range genProviders {
+		envVars = append(envVars, p.APIKeyEnv())
+	}
+	for _, name := range envVars {
+		if key := os.Getenv(name); name != "" && len(key) >= 8 {
+			s = strings.ReplaceAll(s, key, "[REDACTED]")
+		}
+	}
+	for _, re := range secretPatterns {
+		s = re.ReplaceAllString(s, "[REDACTED]")
+	}
+	return s
+}
+
+// loadHistory reads the history log, oldest first. A missing log is empty.
+func loadHistory() ([]historyEntry, error) {
+	path, err := historyPath()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	defer f.Close()
+
+	var entries []historyEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var e historyEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			verbosef("Skipping line %d of %s: %v\n", line, path, err)
+			continue
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	return entries, nil
+}
+
+// matches reports whether the entry's command, model, prompt or response
+// contains query, ignoring case.
+func (e historyEntry) matches(query string) bool {
+	query = strings.ToLower(query)
+	for _, field := range []string{e.Command, e.Model, e.Prompt, e.Response} {
+		if strings.Contains(strings.ToLower(field), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// historyCmd represents the history command
+var historyCmd = &cobra.Command{
+	Use:   "history [search]",
+	Short: "List past code and gen requests from the history log",
+	Long: `Lists the requests recorded in ~/.local/share/vibe/history.jsonl (under
+$XDG_DATA_HOME if set), newest last: when, which command and model, the prompt,
+how many files were sent and the tokens used. Pass a search term to only list
+entries whose prompt, response, model or command contains it.
+
+The history is opt-in: add "history": true to config.json to record every code
+and gen request, and pass --no-history to leave one out. Known API keys and
+common secret formats are redacted, and only the first ` + fmt.Sprint(historyResponseLimit) + ` bytes of each
+response are kept. Token counts marked "~" are estimates, for streamed
+responses that don't report usage.`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		entries, err := loadHistory()
+		if err != nil {
+			return err
+		}
+		if len(args) == 1 {
+			var found []historyEntry
+			for _, e := range entries {
+				if e.matches(args[0]) {
+					found = append(found, e)
+				}
+			}
+			entries = found
+		}
+		if len(entries) == 0 {
+			if !historyEnabled() && len(args) == 0 {
+				logln(`No history yet; add "history": true to config.json to record requests.`)
+			} else {
+				logln("No matching history entries.")
+			}
+			return nil
+		}
+		if historyLimit > 0 && len(entries) > historyLimit {
+			entries = entries[len(entries)-historyLimit:]
+		}
+		for i, e := range entries {
+			if i > 0 {
+				fmt.Println()
+			}
+			printHistoryEntry(e)
+		}
+		return nil
+	},
+}
+
+// printHistoryEntry prints one entry: a summary line, the prompt and, with
+// --full, the stored response.
+func printHistoryEntry(e historyEntry) {
+	tokens := fmt.Sprintf("%d+%d tokens", e.PromptTokens, e.CompletionTokens)
+	if e.Estimated {
+		tokens = "~" + tokens
+	}
+	details := []string{fmt.Sprintf("%d file(s)", e.Files), tokens}
+	if e.Cached {
+		details = append(details, "cached")
+	}
+	fmt.Printf("%s  %s  %s  (%s)\n", e.Time.Local().Format("2006-01-02 15:04"), e.Command, e.Model, strings.Join(details, ", "))
+	prompt := strings.Join(strings.Fields(e.Prompt), " ")
+	if !historyFull {
+		prompt = truncateHistory(prompt, 100, "...")
+	}
+	fmt.Printf("  > %s\n", prompt)
+	if historyFull {
+		for _, line := range strings.Split(strings.TrimRight(e.Response, "\n"), "\n") {
+			fmt.Printf("  %s\n", line)
+		}
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.Flags().IntVarP(&historyLimit, "limit", "n", 20, "List at most this many of the newest entries (0 = all)")
+	historyCmd.Flags().BoolVar(&historyFull, "full", false, "Print the whole prompt and the stored response of each entry")
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"os"
+
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/extension"
+)
+
+// htmlExportTemplate wraps rendered Markdown in a standalone page with basic styling.
+const htmlExportTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>%s</title>
+<style>
+body { max-width: 860px; margin: 2rem auto; padding: 0 1rem; font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif; line-height: 1.6; color: #24292f; }
+h1, h2, h3 { border-bottom: 1px solid #d0d7de; padding-bottom: .3em; }
+code { font-family: SFMono-Regular, Consolas, "Liberation Mono", Menlo, monospace; font-size: 90%%; }
+:not(pre) > code { background: #f6f8fa; padding: .2em .4em; border-radius: 4px; }
+pre { padding: 1rem; overflow-x: auto; border-radius: 6px; }
+table { border-collapse: collapse; } th, td { border: 1px solid #d0d7de; padding: .4em .8em; }
+blockquote { color: #57606a; border-left: .25em solid #d0d7de; margin: 0; padding: 0 1em; }
+</style>
+</head>
+<body>
+%s
+</body>
+</html>
+`
+
+// renderHTMLDocument converts Markdown into a standalone HTML page with
+// syntax-highlighted code blocks.
+func renderHTMLDocument(title, markdown string) ([]byte, error) {
+	md := goldmark.New(
+		goldmark.WithExtensions(
+			extension.GFM,
+			highlighting.NewHighlighting(highlighting.WithStyle("github")),
+		),
+	)
+	var body bytes.Buffer
+	if err := md.Convert([]byte(markdown), &body); err != nil {
+		return nil, fmt.Errorf("failed to convert markdown to HTML: %w", err)
+	}
+	return []byte(fmt.Sprintf(htmlExportTemplate, html.EscapeString(title), body.String())), nil
+}
+
+// writeHTMLExport renders markdown to a standalone HTML file at path.
+func writeHTMLExport(path, title, markdown string) error {
+	doc, err := renderHTMLDocument(title, markdown)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, doc, 0o644); err != nil {
+		return fmt.Errorf("failed to write HTML export %s: %w", path, err)
+	}
+	logf("Wrote HTML export to %s\n", path)
+	return nil
+}
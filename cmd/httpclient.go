@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+var proxyURL string // Flag overriding the HTTP(S)_PROXY environment variables
+
+// newHTTPClient returns the client every API call goes through. It honors
+// HTTPS_PROXY, HTTP_PROXY and NO_PROXY like Go's default transport, unless
+// --proxy names a proxy explicitly. A zero timeout means none.
+func newHTTPClient(timeout time.Duration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxyFunc()
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// proxyFunc returns the transport's proxy selector for the current flags.
+func proxyFunc() func(*http.Request) (*url.URL, error) {
+	if proxyURL == "" {
+		return http.ProxyFromEnvironment
+	}
+	return func(*http.Request) (*url.URL, error) {
+		u, err := url.Parse(proxyURL)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid --proxy %q: expected a URL like http://proxy.example.com:8080", proxyURL)
+		}
+		return u, nil
+	}
+}
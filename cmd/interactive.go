@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var interactiveMode bool // Flag to run code as a read-prompt-respond loop
+
+// interactiveHelp lists the commands understood at the interactive prompt.
+const interactiveHelp = "Commands: /reload (re-gather the context), /model NAME (switch models), /exit"
+
+// runInteractive gathers the context once, then answers prompts read from
+// stdin, keeping the conversation in memory so follow-ups see earlier turns.
+func runInteractive(cmd *cobra.Command, args []string) error {
+	if watchMode || applyChanges || jsonSchemaSpec != "" || completionCount > 1 {
+		return fmt.Errorf("--interactive can't be combined with --watch, --apply, --json-schema or --count")
+	}
+	targetDir := "."
+	if len(args) == 1 {
+		targetDir = args[0]
+	}
+	root, err := resolveTargetDir(targetDir)
+	if err != nil {
+		return err
+	}
+	if llmModel, err = resolveModelAlias(llmModel); err != nil {
+		return err
+	}
+	endpoint, err := resolveCodeEndpoint(cmd)
+	if err != nil {
+		return err
+	}
+	lineRanges, err := resolveLineRanges()
+	if err != nil {
+		return err
+	}
+	systemContent, err := interactiveSystemPrompt(root, lineRanges, endpoint)
+	if err != nil {
+		return err
+	}
+
+	history := []message{{Role: "system", Content: systemContent}}
+	client := newHTTPClient(180 * time.Second)
+	reader := bufio.NewReader(os.Stdin)
+	logf("Chatting with %s. %s\n", llmModel, interactiveHelp)
+	for {
+		fmt.Fprint(os.Stderr, "\nvibe> ")
+		line, readErr := reader.ReadString('\n')
+		prompt := strings.TrimSpace(line)
+		if readErr != nil && prompt == "" {
+			fmt.Fprintln(os.Stderr) // Ctrl-D: end the prompt line
+			return nil
+		}
+
+		switch name, arg, _ := strings.Cut(prompt, " "); {
+		case prompt == "":
+			continue
+		case name == "/exit" || name == "/quit":
+			return nil
+		case name == "/reload":
+			reloaded, err := interactiveSystemPrompt(root, lineRanges, endpoint)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				continue
+			}
+			history[0].Content = reloaded
+			continue
+		case name == "/model":
+			if err := switchInteractiveModel(strings.TrimSpace(arg)); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			}
+			continue
+		case strings.HasPrefix(name, "/"):
+			fmt.Fprintf(os.Stderr, "Unknown command %s. %s\n", name, interactiveHelp)
+			continue
+		}
+
+		request, err := wrapUserPrompt(cmd, prompt)
+		if err != nil {
+			return err
+		}
+		history = append(history, message{Role: "user", Content: request})
+		reply, err := interactiveTurn(client, endpoint, history)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			history = history[:len(history)-1] // Let the user simply ask again
+			continue
+		}
+		history = append(history, message{Role: "assistant", Content: reply})
+	}
+}
+
+// interactiveSystemPrompt gathers the context the way code does (so
+// --from-clipboard, --context-from-git-diff, --context-line-range and
+// --token-budget all apply) and builds the system message that carries it.
+// With --no-context it is the general assistant prompt.
+func interactiveSystemPrompt(root string, lineRanges map[string][]lineRange, endpoint chatEndpoint) (string, error) {
+	if noContext {
+		return withAppendedSystem(generalSystemPrompt)
+	}
+	files, err := gatherCodeContext(root, true, "", lineRanges, endpoint)
+	if err != nil {
+		return "", err
+	}
+
+	preamble, err := loadPreamble()
+	if err != nil {
+		return "", err
+	}
+	footer, err := loadFooter("")
+	if err != nil {
+		return "", err
+	}
+
+	// The context always lives in the system message, so it isn't repeated every turn
+	systemContent, _ := buildCodeMessages(formatFileContext(preamble, files, footer, contextStyle, contextSeparator), "", "system")
+	return withAppendedSystem(systemContent)
+}
+
+// switchInteractiveModel handles /model: it prints the current model, or
+// switches to name (which may be an alias).
+func switchInteractiveModel(name string) error {
+	if name == "" {
+		logf("Current model: %s\n", llmModel)
+		return nil
+	}
+	if useAzure {
+		return fmt.Errorf("/model can't switch away from the --azure deployment")
+	}
+	resolved, err := resolveModelAlias(name)
+	if err != nil {
+		return err
+	}
+	llmModel = resolved
+	logf("Switched to %s.\n", llmModel)
+	return nil
+}
+
+// interactiveTurn sends the conversation so far and streams the reply to stdout.
+func interactiveTurn(client *http.Client, endpoint chatEndpoint, history []message) (string, error) {
+	payload := map[string]interface{}{
+		"model":    llmModel,
+		"messages": history,
+		"stream":   true,
+	}
+	if reasoningEffort != "" {
+		payload["reasoning"] = map[string]string{"effort": reasoningEffort}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	resp, err := postChatCompletion(ctx, client, endpoint, payload)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	result := readStream(resp.Body, cancel, false, nil)
+	fmt.Println()
+	switch {
+	case result.err != nil:
+		return "", result.err
+	case result.content == "" && !result.finished:
+		return "", fmt.Errorf("the stream ended before the model answered")
+	case result.content == "":
+		warnf("empty-response", "Received an empty streaming response from the LLM.\n")
+	case !result.finished:
+		fmt.Fprintln(os.Stderr, "Note: The stream ended before the model finished. Output may be incomplete.")
+	}
+	return result.content, nil
+}
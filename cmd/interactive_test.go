@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestInteractiveSystemPromptContextFlags(t *testing.T) {
+	var lines []string
+	for i := 1; i <= 10; i++ {
+		lines = append(lines, fmt.Sprintf("// line %d", i))
+	}
+	root := writeTree(t, map[string]string{
+		"a.go":   strings.Join(lines, "\n") + "\n",
+		"big.go": "package big\n\n// " + strings.Repeat("filler ", 2000) + "\n",
+	})
+	t.Cleanup(func() { contextLineRanges, forcedFiles, tokenBudget = nil, nil, 0 })
+	contextLineRanges = []string{"a.go:2-3"}
+	tokenBudget = 200
+
+	lineRanges, err := resolveLineRanges()
+	if err != nil {
+		t.Fatal(err)
+	}
+	system, err := interactiveSystemPrompt(root, lineRanges, chatEndpoint{})
+	if err != nil {
+		t.Fatalf("interactiveSystemPrompt: %v", err)
+	}
+	for _, want := range []string{"// line 2\n", "// line 3\n"} {
+		if !strings.Contains(system, want) {
+			t.Errorf("system prompt is missing %q from --context-line-range", want)
+		}
+	}
+	for _, unwanted := range []string{"// line 1\n", "// line 4\n", "filler"} {
+		if strings.Contains(system, unwanted) {
+			t.Errorf("system prompt contains %q, which --context-line-range or --token-budget should have left out", unwanted)
+		}
+	}
+}
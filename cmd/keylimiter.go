@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"sync"
+	"time"
+)
+
+// keyLimiter serializes requests made with the same API key so providers that
+// route through one account (e.g. several OpenRouter models) don't trip per-key
+// rate limits. Keys used by a single provider are never throttled.
+type keyLimiter struct {
+	interval time.Duration       // Minimum gap between requests sharing a key
+	locks    map[string]*keyLock // Only populated for keys shared by 2+ providers
+}
+
+// keyLock guards the requests made with one shared key.
+type keyLock struct {
+	mu   sync.Mutex
+	last time.Time
+}
+
+// newKeyLimiter builds a limiter for the given per-provider API keys.
+func newKeyLimiter(keys []string, interval time.Duration) *keyLimiter {
+	counts := map[string]int{}
+	for _, k := range keys {
+		if k != "" {
+			counts[k]++
+		}
+	}
+	l := &keyLimiter{interval: interval, locks: map[string]*keyLock{}}
+	for k, n := range counts {
+		if n > 1 {
+			l.locks[k] = &keyLock{}
+			verbosef("%d providers share an API key; their requests will be serialized\n", n)
+		}
+	}
+	return l
+}
+
+// acquire blocks until a request with key may be sent and returns the function
+// that releases it. For unshared keys it returns immediately.
+func (l *keyLimiter) acquire(key string) func() {
+	lock, ok := l.locks[key]
+	if !ok {
+		return func() {}
+	}
+	lock.mu.Lock()
+	if wait := l.interval - time.Since(lock.last); !lock.last.IsZero() && wait > 0 {
+		time.Sleep(wait)
+	}
+	return func() {
+		lock.last = time.Now()
+		lock.mu.Unlock()
+	}
+}
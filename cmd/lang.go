@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+)
+
+// commentStyle describes how to write a single-line comment in a language.
+type commentStyle struct {
+	prefix string
+	suffix string // Non-empty for languages that only have block comments (e.g. HTML)
+}
+
+// wrap turns text into a comment in this style.
+func (c commentStyle) wrap(text string) string {
+	if c.suffix != "" {
+		return c.prefix + " " + text + " " + c.suffix
+	}
+	return c.prefix + " " + text
+}
+
+// commentStylesByExt maps lowercase file extensions to their comment syntax.
+var commentStylesByExt = map[string]commentStyle{
+	".go": {prefix: "//"}, ".js": {prefix: "//"}, ".ts": {prefix: "//"}, ".jsx": {prefix: "//"}, ".tsx": {prefix: "//"},
+	".java": {prefix: "//"}, ".kt": {prefix: "//"}, ".scala": {prefix: "//"}, ".swift": {prefix: "//"},
+	".c": {prefix: "//"}, ".h": {prefix: "//"}, ".cpp": {prefix: "//"}, ".hpp": {prefix: "//"}, ".cs": {prefix: "//"},
+	".rs": {prefix: "//"}, ".php": {prefix: "//"}, ".dart": {prefix: "//"}, ".proto": {prefix: "//"},
+	".py": {prefix: "#"}, ".rb": {prefix: "#"}, ".sh": {prefix: "#"}, ".bash": {prefix: "#"}, ".zsh": {prefix: "#"},
+	".pl": {prefix: "#"}, ".r": {prefix: "#"}, ".yaml": {prefix: "#"}, ".yml": {prefix: "#"}, ".toml": {prefix: "#"},
+	".sql": {prefix: "--"}, ".lua": {prefix: "--"}, ".hs": {prefix: "--"},
+	".html": {prefix: "<!--", suffix: "-->"}, ".xml": {prefix: "<!--", suffix: "-->"}, ".md": {prefix: "<!--", suffix: "-->"},
+	".css": {prefix: "/*", suffix: "*/"},
+}
+
+// commentStylesByLang maps languages (as returned by languageFor) of files that
+// are usually extensionless to their comment syntax.
+var commentStylesByLang = map[string]commentStyle{
+	"dockerfile": {prefix: "#"}, "makefile": {prefix: "#"}, "cmake": {prefix: "#"},
+	"ruby": {prefix: "#"}, "bash": {prefix: "#"}, "groovy": {prefix: "//"},
+}
+
+// commentStyleFor returns the comment syntax for path, if it is known.
+func commentStyleFor(path string) (commentStyle, bool) {
+	if style, ok := commentStylesByExt[strings.ToLower(filepath.Ext(path))]; ok {
+		return style, true
+	}
+	style, ok := commentStylesByLang[languageFor(path)]
+	return style, ok
+}
+
+// langOverrides holds --lang-map entries, keyed by lowercase file name or extension.
+var langOverrides map[string]string
+
+// languagesByName maps well-known extensionless (or oddly named) files to the
+// language used to highlight them.
+var languagesByName = map[string]string{
+	"dockerfile":     "dockerfile",
+	"containerfile":  "dockerfile",
+	"makefile":       "makefile",
+	"gnumakefile":    "makefile",
+	"cmakelists.txt": "cmake",
+	"jenkinsfile":    "groovy",
+	"vagrantfile":    "ruby",
+	"gemfile":        "ruby",
+	"rakefile":       "ruby",
+	"podfile":        "ruby",
+	".bashrc":        "bash",
+	".bash_profile":  "bash",
+	".zshrc":         "bash",
+	".profile":       "bash",
+	"go.mod":         "go",
+	"go.sum":         "text",
+}
+
+// languagesByExt maps lowercase file extensions to the language used to highlight them.
+var languagesByExt = map[string]string{
+	".go": "go", ".py": "python", ".js": "javascript", ".jsx": "jsx", ".ts": "typescript", ".tsx": "tsx",
+	".rs": "rust", ".java": "java", ".kt": "kotlin", ".scala": "scala", ".swift": "swift",
+	".c": "c", ".h": "c", ".cpp": "cpp", ".hpp": "cpp", ".cs": "csharp", ".rb": "ruby", ".php": "php",
+	".sh": "bash", ".bash": "bash", ".zsh": "bash", ".sql": "sql", ".lua": "lua", ".hs": "haskell",
+	".html": "html", ".xml": "xml", ".css": "css", ".md": "markdown", ".json": "json",
+	".yaml": "yaml", ".yml": "yaml", ".toml": "toml", ".proto": "protobuf", ".dart": "dart",
+	".mk": "makefile", ".dockerfile": "dockerfile", ".tf": "hcl",
+}
+
+// languageFor returns the highlighting language for path, or "" if unknown.
+// --lang-map overrides win, then exact file names, then extensions.
+func languageFor(path string) string {
+	name := strings.ToLower(filepath.Base(path))
+	ext := strings.ToLower(filepath.Ext(name))
+	if lang, ok := langOverrides[name]; ok {
+		return lang
+	}
+	if lang, ok := langOverrides[ext]; ok && ext != "" {
+		return lang
+	}
+	if lang, ok := languagesByName[name]; ok {
+		return lang
+	}
+	return languagesByExt[ext]
+}
+
+// languagesByInterpreter maps shebang interpreters (with any version suffix
+// removed) to the language used to highlight them.
+var languagesByInterpreter = map[string]string{
+	"sh": "bash", "bash": "bash", "zsh": "bash", "dash": "bash", "ksh": "bash",
+	"python": "python", "ruby": "ruby", "perl": "perl", "php": "php", "lua": "lua",
+	"node": "javascript", "deno": "typescript", "ts-node": "typescript",
+}
+
+// shebangLanguage returns the language named by content's #! line, or "" if it
+// has none or the interpreter is unknown. Both "#!/bin/bash" and
+// "#!/usr/bin/env python3" forms are recognized.
+func shebangLanguage(content []byte) string {
+	if !bytes.HasPrefix(content, []byte("#!")) {
+		return ""
+	}
+	line, _, _ := bytes.Cut(content[2:], []byte("\n"))
+	fields := strings.Fields(string(line))
+	if len(fields) == 0 {
+		return ""
+	}
+	interpreter := filepath.Base(fields[0])
+	if interpreter == "env" {
+		interpreter = ""
+		for _, f := range fields[1:] {
+			if !strings.HasPrefix(f, "-") && !strings.Contains(f, "=") { // Skip env -S and VAR=value
+				interpreter = filepath.Base(f)
+				break
+			}
+		}
+	}
+	// python3, python3.12, perl5 -> python, perl
+	interpreter = strings.TrimRight(interpreter, "0123456789.")
+	return languagesByInterpreter[interpreter]
+}
+
+// languageForContent is languageFor, falling back to the shebang of content
+// for files whose name doesn't identify the language.
+func languageForContent(path string, content []byte) string {
+	if lang := languageFor(path); lang != "" {
+		return lang
+	}
+	return shebangLanguage(content)
+}
+
+// parseLangMap turns --lang-map "name=lang" entries into langOverrides.
+// Keys may be an exact file name ("Justfile") or an extension (".tpl").
+func parseLangMap(entries map[string]string) map[string]string {
+	overrides := make(map[string]string, len(entries))
+	for k, v := range entries {
+		overrides[strings.ToLower(strings.TrimSpace(k))] = strings.TrimSpace(v)
+	}
+	return overrides
+}
+
+// fencedCodeBlock wraps content in a Markdown code fence tagged with lang,
+// using a fence longer than any backtick run inside the content.
+func fencedCodeBlock(lang, content string) string {
+	fence := "```"
+	for strings.Contains(content, fence) {
+		fence += "`"
+	}
+	return fence + lang + "\n" + strings.TrimRight(content, "\n") + "\n" + fence
+}
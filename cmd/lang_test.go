@@ -0,0 +1,28 @@
+package cmd
+
+import "testing"
+
+func TestShebangLanguage(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"absolute interpreter", "#!/bin/bash\necho hi\n", "bash"},
+		{"env with versioned interpreter", "#!/usr/bin/env python3\nprint('hi')\n", "python"},
+		{"env -S with interpreter flags", "#!/usr/bin/env -S node --flag\nconsole.log('hi')\n", "javascript"},
+		{"env with variable assignment", "#!/usr/bin/env LC_ALL=C perl\n", "perl"},
+		{"CRLF line ending", "#!/bin/sh\r\n", "bash"},
+		{"unknown interpreter", "#!/usr/bin/awk -f\n", ""},
+		{"no shebang", "just some notes\n", ""},
+		{"bare #!", "#!\n", ""},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shebangLanguage([]byte(tt.content)); got != tt.want {
+				t.Errorf("shebangLanguage(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
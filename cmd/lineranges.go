@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var contextLineRanges []string // Flag for FILE:START-END ranges to send instead of the whole file
+
+// lineRange is a 1-based, inclusive range of lines.
+type lineRange struct {
+	start, end int
+}
+
+// parseLineRanges parses --context-line-range values such as "foo.go:100-160",
+// "foo.go:42" or "foo.go:10-20,90-99" into each file's ranges, sorted and with
+// overlapping or adjacent ranges merged. Paths stay as given (relative to the
+// target directory unless absolute).
+func parseLineRanges(specs []string) (map[string][]lineRange, error) {
+	ranges := map[string][]lineRange{}
+	for _, spec := range specs {
+		i := strings.LastIndex(spec, ":")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid --context-line-range %q: want FILE:START-END, e.g. foo.go:100-160", spec)
+		}
+		path := spec[:i]
+		for _, part := range strings.Split(spec[i+1:], ",") {
+			first, last, isRange := strings.Cut(part, "-")
+			if !isRange {
+				last = first
+			}
+			start, err1 := strconv.Atoi(strings.TrimSpace(first))
+			end, err2 := strconv.Atoi(strings.TrimSpace(last))
+			if err1 != nil || err2 != nil || start < 1 || end < start {
+				return nil, fmt.Errorf("invalid --context-line-range %q: %q is not a line range like 100-160", spec, part)
+			}
+			ranges[path] = append(ranges[path], lineRange{start, end})
+		}
+	}
+	for path, rs := range ranges {
+		slices.SortFunc(rs, func(a, b lineRange) int { return a.start - b.start })
+		merged := rs[:1]
+		for _, r := range rs[1:] {
+			if last := &merged[len(merged)-1]; r.start <= last.end+1 {
+				last.end = max(last.end, r.end)
+			} else {
+				merged = append(merged, r)
+			}
+		}
+		ranges[path] = merged
+	}
+	return ranges, nil
+}
+
+// resolveLineRanges parses --context-line-range and adds its files to --file,
+// so that the filters can't skip a file the user asked for lines of.
+func resolveLineRanges() (map[string][]lineRange, error) {
+	ranges, err := parseLineRanges(contextLineRanges)
+	if err != nil {
+		return nil, err
+	}
+	if len(ranges) > 0 && (noContext || fromClipboard || contextFromDiff) {
+		return nil, fmt.Errorf("--context-line-range selects lines of gathered files; it can't be combined with --no-context, --from-clipboard or --context-from-git-diff")
+	}
+	for path := range ranges {
+		if !slices.Contains(forcedFiles, path) {
+			forcedFiles = append(forcedFiles, path) // Send it even if the filters would skip it
+		}
+	}
+	return ranges, nil
+}
+
+// applyLineRanges replaces the content of each file with ranges by just those
+// lines, noting the omitted lines (as a comment in the file's language when it
+// is known) so the model knows it is seeing part of a larger file. A range
+// for a path that matches none of the files is warned about, since that file
+// is then either missing or sent whole.
+func applyLineRanges(files []gatheredFile, root string, ranges map[string][]lineRange) []gatheredFile {
+	byPath := map[string][]lineRange{}
+	given := map[string]string{} // The path as given to --context-line-range
+	for path, rs := range ranges {
+		abs := path
+		if !filepath.IsAbs(abs) {
+			abs = filepath.Join(root, abs)
+		}
+		abs = filepath.Clean(abs)
+		byPath[abs] = rs
+		given[abs] = path
+	}
+	matched := map[string]bool{}
+	for i, f := range files {
+		rs, ok := byPath[f.path]
+		if !ok {
+			continue
+		}
+		matched[f.path] = true
+		lines := bytes.SplitAfter(f.content, []byte("\n"))
+		if len(lines) > 0 && len(lines[len(lines)-1]) == 0 {
+			lines = lines[:len(lines)-1] // SplitAfter leaves an empty element after a final newline
+		}
+		total := len(lines)
+		note := func(from, to int) string {
+			text := fmt.Sprintf("... lines %d-%d of %d omitted ...", from, to, total)
+			if from == to {
+				text = fmt.Sprintf("... line %d of %d omitted ...", from, total)
+			}
+			if style, ok := commentStyleFor(f.path); ok {
+				text = style.wrap(text)
+			}
+			return text + "\n"
+		}
+
+		var b bytes.Buffer
+		next, shown := 1, 0 // next is the first line not yet shown or noted
+		for _, r := range rs {
+			if r.start > total {
+				warnf("line-range", "--context-line-range %d-%d is past the end of %s (%d lines)\n", r.start, r.end, f.path, total)
+				continue
+			}
+			end := min(r.end, total)
+			if r.start > next {
+				b.WriteString(note(next, r.start-1))
+			}
+			for _, line := range lines[r.start-1 : end] {
+				b.Write(line)
+			}
+			if !bytes.HasSuffix(b.Bytes(), []byte("\n")) {
+				b.WriteString("\n")
+			}
+			shown += end - r.start + 1
+			next = end + 1
+		}
+		if next <= total {
+			b.WriteString(note(next, total))
+		}
+		verbosef("Sending %d of %d lines of %s\n", shown, total, f.path)
+		files[i].content = b.Bytes()
+	}
+	var unmatched []string
+	for path := range byPath {
+		if !matched[path] {
+			unmatched = append(unmatched, given[path])
+		}
+	}
+	slices.Sort(unmatched)
+	for _, path := range unmatched {
+		warnf("line-range", "--context-line-range %s matches no gathered file; check the path (relative to %s)\n", path, root)
+	}
+	return files
+}
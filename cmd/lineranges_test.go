@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseLineRangesMerges(t *testing.T) {
+	tests := []struct {
+		name  string
+		specs []string
+		want  []lineRange
+	}{
+		{"single line", []string{"a.go:42"}, []lineRange{{42, 42}}},
+		{"sorted", []string{"a.go:90-99,10-20"}, []lineRange{{10, 20}, {90, 99}}},
+		{"overlapping", []string{"a.go:10-20,15-30"}, []lineRange{{10, 30}}},
+		{"contained", []string{"a.go:10-40,15-20"}, []lineRange{{10, 40}}},
+		{"adjacent", []string{"a.go:10-20,21-30"}, []lineRange{{10, 30}}},
+		{"gap of one line", []string{"a.go:10-20,22-30"}, []lineRange{{10, 20}, {22, 30}}},
+		{"across flags", []string{"a.go:1-5", "a.go:6-8", "a.go:3"}, []lineRange{{1, 8}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseLineRanges(tt.specs)
+			if err != nil {
+				t.Fatalf("parseLineRanges(%q): %v", tt.specs, err)
+			}
+			if !reflect.DeepEqual(got["a.go"], tt.want) {
+				t.Errorf("parseLineRanges(%q) = %v, want %v", tt.specs, got["a.go"], tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyLineRangesUnmatchedPath(t *testing.T) {
+	t.Cleanup(func() { suppressedWarnings = nil })
+	files := []gatheredFile{{path: "/repo/a.go", content: []byte("one\ntwo\nthree\n")}}
+	ranges := map[string][]lineRange{
+		"a.go":      {{2, 2}},
+		"typo.go":   {{1, 3}},
+		"/other.go": {{1, 1}},
+	}
+
+	var got []gatheredFile
+	stderr := captureStderr(t, func() { got = applyLineRanges(files, "/repo", ranges) })
+	for _, path := range []string{"typo.go", "/other.go"} {
+		if !strings.Contains(stderr, "--context-line-range "+path+" matches no gathered file") {
+			t.Errorf("no warning about %s in:\n%s", path, stderr)
+		}
+	}
+	if strings.Contains(stderr, "a.go matches") {
+		t.Errorf("warned about a.go, which matched:\n%s", stderr)
+	}
+	if !strings.Contains(string(got[0].content), "two\n") || strings.Contains(string(got[0].content), "one\n") {
+		t.Errorf("a.go content = %q, want only line 2 and notes", got[0].content)
+	}
+
+	suppressedWarnings = []string{"line-range"}
+	if stderr := captureStderr(t, func() { applyLineRanges(files, "/repo", ranges) }); stderr != "" {
+		t.Errorf("--suppress-warnings line-range printed %q", stderr)
+	}
+}
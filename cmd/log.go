@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+var (
+	quiet              bool     // Flag variable to silence informational stderr output
+	suppressedWarnings []string // Flag variable for warning categories not to print
+)
+
+// warningCategories are the categories --suppress-warnings accepts, besides
+// "all".
+var warningCategories = []string{
+	"large", "unreadable", "symlink", "binary", "stale", "no-files", "decode", "budget", "line-range", "strip-comments",
+	"apply", "fallback", "resume", "empty-response", "cache", "truncated", "config", "cost", "clipboard", "browser",
+	"export", "debug-responses", "history", "pager", "post-process", "watch",
+}
+
+// logf prints an informational progress message to stderr unless --quiet is set.
+// Warnings go through warnf and errors to stderr directly, so --quiet never hides them.
+func logf(format string, a ...interface{}) {
+	if quiet {
+		return
+	}
+	fmt.Fprintf(os.Stderr, format, a...)
+}
+
+// logln is the Println variant of logf.
+func logln(a ...interface{}) {
+	if quiet {
+		return
+	}
+	fmt.Fprintln(os.Stderr, a...)
+}
+
+// warnf prints a "Warning: " message to stderr unless its category (one of
+// warningCategories) was silenced with --suppress-warnings. Leading newlines
+// in format, which end a line of streamed output, go before the "Warning: ".
+func warnf(category, format string, a ...interface{}) {
+	if slices.Contains(suppressedWarnings, category) || slices.Contains(suppressedWarnings, "all") {
+		return
+	}
+	body := strings.TrimLeft(format, "\n")
+	fmt.Fprintf(os.Stderr, format[:len(format)-len(body)]+"Warning: "+body, a...)
+}
+
+// checkWarningCategories validates the --suppress-warnings values.
+func checkWarningCategories() error {
+	for _, c := range suppressedWarnings {
+		if c != "all" && !slices.Contains(warningCategories, c) {
+			return fmt.Errorf("invalid --suppress-warnings category %q: must be all or one of %s", c, strings.Join(warningCategories, ", "))
+		}
+	}
+	return nil
+}
+
+// verbosef prints a diagnostic message to stderr when --verbose is set (and --quiet isn't).
+func verbosef(format string, a ...interface{}) {
+	if !verbose {
+		return
+	}
+	logf(format, a...)
+}
+
+// dimText wraps s in the ANSI "faint" attribute when stderr is a terminal and
+// NO_COLOR isn't set, for secondary output like reasoning traces.
+func dimText(s string) string {
+	info, err := os.Stderr.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 || os.Getenv("NO_COLOR") != "" {
+		return s
+	}
+	return "\033[2m" + s + "\033[0m"
+}
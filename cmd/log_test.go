@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"io"
+	"os"
+	"testing"
+)
+
+// captureStderr returns what fn writes to os.Stderr.
+func captureStderr(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr := os.Stderr
+	os.Stderr = w
+	defer func() { os.Stderr = stderr }()
+	fn()
+	w.Close()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestWarnf(t *testing.T) {
+	t.Cleanup(func() { suppressedWarnings = nil })
+	tests := []struct {
+		name       string
+		suppressed []string
+		category   string
+		format     string
+		want       string
+	}{
+		{"printed", nil, "cache", "Failed to cache: %s\n", "Warning: Failed to cache: disk full\n"},
+		{"other category suppressed", []string{"large"}, "cache", "Failed to cache: %s\n", "Warning: Failed to cache: disk full\n"},
+		{"category suppressed", []string{"large", "cache"}, "cache", "Failed to cache: %s\n", ""},
+		{"all suppressed", []string{"all"}, "truncated", "Cut: %s\n", ""},
+		{"leading newline", nil, "resume", "\nResuming: %s\n", "\nWarning: Resuming: disk full\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			suppressedWarnings = tt.suppressed
+			got := captureStderr(t, func() { warnf(tt.category, tt.format, "disk full") })
+			if got != tt.want {
+				t.Errorf("warnf printed %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckWarningCategories(t *testing.T) {
+	t.Cleanup(func() { suppressedWarnings = nil })
+	for _, c := range append([]string{"all"}, warningCategories...) {
+		suppressedWarnings = []string{c}
+		if err := checkWarningCategories(); err != nil {
+			t.Errorf("checkWarningCategories(%q): %v", c, err)
+		}
+	}
+	suppressedWarnings = []string{"cache", "nope"}
+	if err := checkWarningCategories(); err == nil {
+		t.Error("checkWarningCategories accepted an unknown category")
+	}
+}
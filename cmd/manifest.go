@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// contextManifest records which files a code request was sent with, for --manifest.
+type contextManifest struct {
+	CreatedAt time.Time      `json:"created_at"`
+	Model     string         `json:"model"`
+	Prompt    string         `json:"prompt"`
+	Files     []manifestFile `json:"files"`
+}
+
+// manifestFile is one entry of a contextManifest. Size and SHA256 describe the
+// content as sent, after whitespace stripping, truncation or summarizing.
+type manifestFile struct {
+	Path   string `json:"path"`
+	Size   int    `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// writeManifest writes the JSON manifest of the final context files to path.
+func writeManifest(path, prompt string, files []gatheredFile) error {
+	manifest := contextManifest{CreatedAt: time.Now().UTC(), Model: llmModel, Prompt: prompt, Files: []manifestFile{}}
+	for _, f := range files {
+		sum := sha256.Sum256(f.content)
+		manifest.Files = append(manifest.Files, manifestFile{Path: f.path, Size: len(f.content), SHA256: hex.EncodeToString(sum[:])})
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write manifest %s: %w", path, err)
+	}
+	logf("Wrote manifest of %d file(s) to %s\n", len(files), path)
+	return nil
+}
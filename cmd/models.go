@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/daviddl9/vibe/internal/version"
+	"github.com/spf13/cobra"
+)
+
+const (
+	openRouterModelsURL = "https://openrouter.ai/api/v1/models"
+	modelsCacheTTL      = time.Hour // How long the on-disk models list stays fresh
+)
+
+var refreshModels bool // Flag to ignore the cached models list
+
+// modelInfo is one entry of OpenRouter's models list.
+type modelInfo struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	ContextLength int    `json:"context_length"`
+	Pricing       struct {
+		Prompt     string `json:"prompt"`     // USD per prompt token, as a decimal string
+		Completion string `json:"completion"` // USD per completion token
+	} `json:"pricing"`
+}
+
+// modelsCacheEntry is the models list as stored on disk.
+type modelsCacheEntry struct {
+	FetchedAt time.Time   `json:"fetched_at"`
+	Models    []modelInfo `json:"models"`
+}
+
+var fetchedModels []modelInfo // Memoized by openRouterModels for the rest of the invocation
+
+// modelsCachePath returns where the models list is cached (~/.cache/vibe/models.json on Linux).
+func modelsCachePath() (string, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate cache directory: %w", err)
+	}
+	return filepath.Join(base, "vibe", "models.json"), nil
+}
+
+// openRouterModels returns OpenRouter's models list, fetching it at most once
+// per invocation and at most once per modelsCacheTTL across invocations unless
+// refresh is set.
+func openRouterModels(refresh bool) ([]modelInfo, error) {
+	if fetchedModels != nil && !refresh {
+		return fetchedModels, nil
+	}
+	path, err := modelsCachePath()
+	if err != nil {
+		return nil, err
+	}
+	if !refresh {
+		if data, err := os.ReadFile(path); err == nil {
+			var entry modelsCacheEntry
+			if json.Unmarshal(data, &entry) == nil && len(entry.Models) > 0 && time.Since(entry.FetchedAt) < modelsCacheTTL {
+				verbosef("Using the models list cached %s ago (pass --refresh to re-fetch)\n", time.Since(entry.FetchedAt).Round(time.Second))
+				fetchedModels = entry.Models
+				return fetchedModels, nil
+			}
+		}
+	}
+
+	verbosef("Fetching the models list from %s\n", openRouterModelsURL)
+	req, err := http.NewRequest("GET", openRouterModelsURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	req.Header.Set("User-Agent", version.UserAgent())
+	client := newHTTPClient(30 * time.Second)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the models list: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the models list: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("received non-OK status code fetching the models list: %d - %s", resp.StatusCode, string(body))
+	}
+	var list struct {
+		Data []modelInfo `json:"data"`
+	}
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, fmt.Errorf("failed to decode the models list: %w", err)
+	}
+	fetchedModels = list.Data
+
+	if data, err := json.Marshal(modelsCacheEntry{FetchedAt: time.Now(), Models: list.Data}); err == nil {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err == nil {
+			err = os.WriteFile(path, data, 0o644)
+		}
+		if err != nil {
+			warnf("cache", "Failed to cache the models list: %v\n", err)
+		}
+	}
+	return fetchedModels, nil
+}
+
+// perMillion formats a per-token USD price string as dollars per million tokens.
+func perMillion(perToken string) string {
+	price, err := strconv.ParseFloat(perToken, 64)
+	if err != nil {
+		return "?"
+	}
+	return fmt.Sprintf("$%.2f", price*1e6)
+}
+
+// modelsCmd represents the models command
+var modelsCmd = &cobra.Command{
+	Use:   "models [filter]",
+	Short: "List the models available through OpenRouter",
+	Long: `Lists OpenRouter's models with their context length and price per million
+prompt and completion tokens. Pass a filter to only list models whose ID
+contains it, e.g. "vibe models anthropic".
+
+The list is cached under ~/.cache/vibe/models.json for an hour, so repeated
+runs (and other model-aware features) don't refetch it; use --refresh to force
+a new fetch.`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true, // Failures are network problems, not usage errors
+	RunE: func(cmd *cobra.Command, args []string) error {
+		models, err := openRouterModels(refreshModels)
+		if err != nil {
+			return err
+		}
+		filter := ""
+		if len(args) == 1 {
+			filter = strings.ToLower(args[0])
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "MODEL\tCONTEXT\tPROMPT/M\tCOMPLETION/M")
+		for _, m := range models {
+			if filter != "" && !strings.Contains(strings.ToLower(m.ID), filter) {
+				continue
+			}
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", m.ID, m.ContextLength, perMillion(m.Pricing.Prompt), perMillion(m.Pricing.Completion))
+		}
+		return w.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(modelsCmd)
+	modelsCmd.Flags().BoolVar(&refreshModels, "refresh", false, "Re-fetch the models list instead of using the cached copy")
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import "sync/atomic"
+
+var maxOpenFiles int // Flag for how many files the walker may have open at once (0 = no limit)
+
+// fileSlots bounds how many files are open at once, so that gathering a large
+// tree can't run out of file descriptors on systems with a low ulimit.
+type fileSlots struct {
+	sem  chan struct{} // nil means no limit
+	open atomic.Int32
+	peak atomic.Int32 // The most files that were open at once
+}
+
+// newFileSlots returns slots for at most limit open files, or unlimited ones
+// if limit is 0.
+func newFileSlots(limit int) *fileSlots {
+	s := &fileSlots{}
+	if limit > 0 {
+		s.sem = make(chan struct{}, limit)
+	}
+	return s
+}
+
+// acquire waits for a free slot and returns the func that releases it, to be
+// called once the file is closed.
+func (s *fileSlots) acquire() (release func()) {
+	if s.sem != nil {
+		s.sem <- struct{}{}
+	}
+	open := s.open.Add(1)
+	for {
+		peak := s.peak.Load()
+		if open <= peak || s.peak.CompareAndSwap(peak, open) {
+			break
+		}
+	}
+	return func() {
+		s.open.Add(-1)
+		if s.sem != nil {
+			<-s.sem
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileSlotsLimit(t *testing.T) {
+	for _, limit := range []int{1, 3, 8} {
+		t.Run(fmt.Sprint(limit), func(t *testing.T) {
+			slots := newFileSlots(limit)
+			var wg sync.WaitGroup
+			for i := 0; i < 50; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					release := slots.acquire()
+					time.Sleep(time.Millisecond)
+					release()
+				}()
+			}
+			wg.Wait()
+			if peak := slots.peak.Load(); peak > int32(limit) {
+				t.Errorf("%d files open at once, want at most %d", peak, limit)
+			}
+			if open := slots.open.Load(); open != 0 {
+				t.Errorf("%d slots still held after every release", open)
+			}
+		})
+	}
+}
+
+// openFDs counts this process's open file descriptors, or returns -1 where
+// /proc isn't available.
+func openFDs() int {
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return -1
+	}
+	return len(entries)
+}
+
+func TestGatherFilesManyFilesLowCap(t *testing.T) {
+	files := map[string]string{}
+	for i := 0; i < 300; i++ {
+		files[fmt.Sprintf("pkg%d/f%d.go", i%10, i)] = fmt.Sprintf("package p // %d\n", i)
+	}
+	files["tool"] = "#!/bin/sh\necho tool\n" // Opened by the shebang check too
+	root := writeTree(t, files)
+	defaultMax := maxOpenFiles
+	maxOpenFiles, excludeGenerated = 1, true
+	t.Cleanup(func() { maxOpenFiles, excludeGenerated = defaultMax, false })
+
+	before := openFDs()
+	got, _, err := gatherFiles(codeWalkOptions(root))
+	if err != nil {
+		t.Fatalf("gatherFiles: %v", err)
+	}
+	if len(got) != len(files) {
+		t.Errorf("gathered %d files, want %d", len(got), len(files))
+	}
+	if after := openFDs(); before >= 0 && after > before {
+		t.Errorf("%d file descriptors open after the walk, %d before", after, before)
+	}
+
+	maxOpenFiles = -1
+	if _, _, err := gatherFiles(codeWalkOptions(root)); err == nil {
+		t.Error("gatherFiles accepted a negative --max-open-files")
+	}
+}
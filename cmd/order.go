@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+var orderByRelevance bool // Flag to put the files that best match the prompt first
+
+// contextOrder is a flag value selecting the order gathered files appear in.
+type contextOrder string
+
+// contextOrders lists the accepted --context-order values.
+var contextOrders = []string{"alpha", "size-asc", "size-desc", "mtime"}
+
+func (o *contextOrder) String() string {
+	if *o == "" {
+		return "alpha"
+	}
+	return string(*o)
+}
+
+func (o *contextOrder) Set(s string) error {
+	if !slices.Contains(contextOrders, s) {
+		return fmt.Errorf("must be one of %s", strings.Join(contextOrders, ", "))
+	}
+	*o = contextOrder(s)
+	return nil
+}
+
+func (o *contextOrder) Type() string {
+	return "order"
+}
+
+// sortFiles reorders files in place. "alpha" keeps the walk's lexical order;
+// "mtime" puts the most recently modified files last. Ties keep walk order.
+func (o contextOrder) sortFiles(files []gatheredFile) {
+	switch o {
+	case "size-asc":
+		sort.SliceStable(files, func(i, j int) bool { return len(files[i].content) < len(files[j].content) })
+	case "size-desc":
+		sort.SliceStable(files, func(i, j int) bool { return len(files[i].content) > len(files[j].content) })
+	case "mtime":
+		sort.SliceStable(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	}
+}
+
+// relevanceStopWords are common prompt words that say nothing about which files matter.
+var relevanceStopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "with": true, "this": true, "that": true,
+	"from": true, "into": true, "add": true, "fix": true, "make": true, "use": true,
+	"how": true, "what": true, "why": true, "does": true, "should": true, "can": true,
+	"file": true, "code": true, "function": true, "please": true,
+}
+
+// promptKeywords splits the prompt into lowercase words and identifiers of at
+// least three characters, dropping stop words and duplicates.
+func promptKeywords(prompt string) []string {
+	words := strings.FieldsFunc(strings.ToLower(prompt), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+	var keywords []string
+	for _, w := range words {
+		if len(w) >= 3 && !relevanceStopWords[w] && !slices.Contains(keywords, w) {
+			keywords = append(keywords, w)
+		}
+	}
+	return keywords
+}
+
+// relevanceScores scores each file by how often the prompt's keywords occur
+// in its content, counting a keyword in the file's name ten times over.
+func relevanceScores(files []gatheredFile, prompt string) []int {
+	keywords := promptKeywords(prompt)
+	scores := make([]int, len(files))
+	for i, f := range files {
+		content := bytes.ToLower(f.content)
+		name := strings.ToLower(filepath.Base(f.path))
+		for _, k := range keywords {
+			scores[i] += bytes.Count(content, []byte(k))
+			if strings.Contains(name, k) {
+				scores[i] += 10
+			}
+		}
+	}
+	return scores
+}
+
+// sortByRelevance reorders files in place, highest relevanceScores first.
+// Ties keep their current order.
+func sortByRelevance(files []gatheredFile, prompt string) {
+	scores := relevanceScores(files, prompt)
+	order := make([]int, len(files))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return scores[order[a]] > scores[order[b]] })
+	sorted := make([]gatheredFile, len(files))
+	for i, j := range order {
+		sorted[i] = files[j]
+	}
+	copy(files, sorted)
+}
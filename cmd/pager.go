@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/glamour/styles"
+)
+
+// defaultPager is used for --pager when $PAGER is unset. -R lets less pass
+// through the ANSI colors of the rendered Markdown.
+const defaultPager = "less -R"
+
+// showResponse prints a complete response, or renders it as Markdown and pages
+// it when page is set. If the pager can't run, the response is printed instead.
+func showResponse(text string, page bool) {
+	if page {
+		err := pageOutput(text)
+		if err == nil {
+			return
+		}
+		warnf("pager", "%v\n", err)
+	}
+	fmt.Println(text)
+}
+
+// codeJSONOutput is the object code --format json prints.
+type codeJSONOutput struct {
+	Model   string   `json:"model"`
+	Cached  bool     `json:"cached"`
+	Choices []string `json:"choices"`
+}
+
+// showCodeResponse prints code's complete response in --format: a JSON
+// object naming the model, Markdown rendered for the terminal, or plain text.
+// Either of the last two is paged with page.
+func showCodeResponse(choices []string, model string, cached, page bool) error {
+	switch {
+	case codeFormat == formatJSON:
+		if choices == nil {
+			choices = []string{}
+		}
+		return printJSON(codeJSONOutput{Model: model, Cached: cached, Choices: choices})
+	case codeFormat == formatMarkdown && !page:
+		renderer, err := newMarkdownRenderer(styles.AutoStyle)
+		if err != nil {
+			return err
+		}
+		fmt.Print(renderMarkdown(renderer, formatChoices(choices)))
+		return nil
+	}
+	showResponse(formatChoices(choices), page)
+	return nil
+}
+
+// pageOutput renders md and pipes it through $PAGER.
+func pageOutput(md string) error {
+	pager := os.Getenv("PAGER")
+	if pager == "" {
+		pager = defaultPager
+	}
+	text := md
+	if renderer, err := newMarkdownRenderer(styles.AutoStyle); err == nil {
+		text = renderMarkdown(renderer, md)
+	}
+	c := shellCommand(pager)
+	c.Stdin = strings.NewReader(text)
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("pager %q failed: %w", pager, err)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// runPostProcess pipes input through the shell command userCmd and returns its stdout.
+// On failure, the returned error includes the command's stderr.
+func runPostProcess(userCmd, input string) (string, error) {
+	c := shellCommand(userCmd)
+	c.Stdin = strings.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	c.Stdout = &stdout
+	c.Stderr = &stderr
+	if err := c.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			return "", fmt.Errorf("post-process command %q failed: %w", userCmd, err)
+		}
+		return "", fmt.Errorf("post-process command %q failed: %w\n%s", userCmd, err, msg)
+	}
+	return stdout.String(), nil
+}
+
+// shellCommand returns a command that runs userCmd through the platform's shell.
+func shellCommand(userCmd string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", userCmd)
+	}
+	return exec.Command("sh", "-c", userCmd)
+}
+
+// postProcessResponse applies the --post-process command to a response. With
+// scope "blocks" each fenced code block body is piped through the command on its
+// own (so tools like gofmt see only code); with scope "response" the whole text is.
+// Failures are reported on stderr and the unprocessed text is kept.
+func postProcessResponse(userCmd, scope, response string) string {
+	if scope == "response" {
+		out, err := runPostProcess(userCmd, response)
+		if err != nil {
+			warnf("post-process", "%v\nShowing the unprocessed response.\n", err)
+			return response
+		}
+		return out
+	}
+
+	blocks := findCodeBlocks(response)
+	if len(blocks) == 0 {
+		return response
+	}
+	var b strings.Builder
+	last := 0
+	for i, block := range blocks {
+		b.WriteString(response[last:block.start])
+		out, err := runPostProcess(userCmd, block.body)
+		if err != nil {
+			warnf("post-process", "code block %d: %v\nKeeping it unprocessed.\n", i+1, err)
+			out = block.body
+		}
+		if out != "" && !strings.HasSuffix(out, "\n") {
+			out += "\n"
+		}
+		b.WriteString(out)
+		last = block.end
+	}
+	b.WriteString(response[last:])
+	return b.String()
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/term"
+)
+
+var streamBufferRender bool // Flag to preview the raw stream, then replace it with the rendered Markdown
+
+// streamPreview shows a streaming response dimmed on the terminal while it is
+// generated, counting the rows it takes up so clear can erase it before the
+// rendered response is printed in its place.
+type streamPreview struct {
+	width int  // Terminal columns
+	dim   bool // Dim the text (not with NO_COLOR)
+	rows  int  // Rows completed since the preview began
+	col   int  // Column of the cursor in the current row
+}
+
+// newStreamPreview starts a preview on stdout, which must be a terminal.
+func newStreamPreview() *streamPreview {
+	width, _, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 {
+		width = 80
+	}
+	return &streamPreview{width: width, dim: os.Getenv("NO_COLOR") == ""}
+}
+
+// write prints a delta of the response, keeping track of the rows the text
+// wraps onto. Runes are taken to be one column wide, and a tab to reach the
+// next multiple of 8.
+func (p *streamPreview) write(delta string) {
+	for _, r := range delta {
+		switch r {
+		case '\n':
+			p.rows++
+			p.col = 0
+		case '\t':
+			p.col += 8 - p.col%8
+		default:
+			p.col++
+		}
+		if p.col > p.width {
+			p.rows++
+			p.col = 1
+		}
+	}
+	if p.dim {
+		fmt.Print("\033[2m" + delta + "\033[0m")
+	} else {
+		fmt.Print(delta)
+	}
+}
+
+// clear erases the preview, leaving the cursor where it began. Rows that have
+// already scrolled off the top of the terminal can't be reached and stay in
+// the scrollback.
+func (p *streamPreview) clear() {
+	if p.rows > 0 {
+		fmt.Printf("\r\033[%dA\033[J", p.rows)
+	} else {
+		fmt.Print("\r\033[J")
+	}
+	p.rows, p.col = 0, 0
+}
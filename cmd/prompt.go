@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"strings"
+)
+
+// codeSystemPrompt is the system prompt for the code command. The %s verbs are
+// where the file context lives ("below" or "in the user's message") and the
+// context block itself (empty when the context is sent in the user turn).
+const codeSystemPrompt = `You are an expert programming assistant integrated into a CLI tool called 'vibe'.
+The user is working in the project context provided %s (code files from their directory).
+Analyze the user's request and the provided file context carefully.
+Generate the necessary code modifications, additions, or provide explanations as requested.
+Format your response clearly using Markdown. Use language-specific code blocks (e.g., ` + "```" + `go ... ` + "```" + `, ` + "```" + `python ... ` + "```" + `).
+If modifying existing code, clearly indicate the file and the changes. If adding new code, suggest where it should go.
+Focus on fulfilling the user's request accurately based *only* on the provided context and general programming best practices for the relevant language(s).
+Do not add extraneous conversation or introductory/concluding remarks outside of the requested code/explanation.%s`
+
+// generalSystemPrompt is the system prompt for code --no-context, where the
+// request is a general question with no files attached.
+const generalSystemPrompt = `You are an expert programming assistant integrated into a CLI tool called 'vibe'.
+Answer the user's programming question accurately and concisely, following best practices for the relevant language(s).
+Format your response clearly using Markdown. Use language-specific code blocks (e.g., ` + "```" + `go ... ` + "```" + `, ` + "```" + `python ... ` + "```" + `).
+Do not add extraneous conversation or introductory/concluding remarks.`
+
+var preambleFile string // Flag for a file whose content leads the context block
+
+// loadPreamble returns the --preamble-file content, or "" without one.
+func loadPreamble() (string, error) {
+	if preambleFile == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(preambleFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read --preamble-file: %w", err)
+	}
+	return string(data), nil
+}
+
+var footerFile string // Flag for a file whose content closes the context block
+
+// loadFooter returns the --footer-file content, or fallback without one.
+func loadFooter(fallback string) (string, error) {
+	if footerFile == "" {
+		return fallback, nil
+	}
+	data, err := os.ReadFile(footerFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read --footer-file: %w", err)
+	}
+	return string(data), nil
+}
+
+// contextStyles lists the accepted --context-style values.
+var contextStyles = []string{"markers", "xml", "none"}
+
+// formatFileContext builds the context block for the prompt from the gathered
+// files. style selects the framing: "markers" (// File: headers between
+// FILE CONTEXT START/END lines), "xml" (<file path="..."> entries inside
+// <files>) or "none" (the // File: entries with no surrounding markers).
+// Outside xml, entries are joined by separator on its own line. A
+// --readme-first README is marked as the project overview, files gathered
+// from several --dir roots name their root, a --preamble-file preamble
+// comes before all the files and a --footer-file footer after them.
+func formatFileContext(preamble string, files []gatheredFile, footer, style, separator string) string {
+	var b strings.Builder
+	preamble = strings.TrimRight(preamble, "\n")
+	footer = strings.TrimRight(footer, "\n")
+	if style == "xml" {
+		b.WriteString("<files>\n")
+		if preamble != "" {
+			fmt.Fprintf(&b, "<preamble>\n%s\n</preamble>\n", preamble)
+		}
+		for _, f := range files {
+			fmt.Fprintf(&b, "<file path=\"%s\"", html.EscapeString(f.path))
+			if f.root != "" {
+				fmt.Fprintf(&b, " root=\"%s\"", html.EscapeString(f.root))
+			}
+			if f.overview {
+				b.WriteString(" role=\"project-overview\"")
+			}
+			b.WriteString(">\n")
+			b.Write(f.content)
+			if len(f.content) > 0 && f.content[len(f.content)-1] != '\n' {
+				b.WriteString("\n")
+			}
+			b.WriteString("</file>\n")
+		}
+		if footer != "" {
+			fmt.Fprintf(&b, "<footer>\n%s\n</footer>\n", footer)
+		}
+		b.WriteString("</files>")
+		return b.String()
+	}
+
+	if preamble != "" {
+		fmt.Fprintf(&b, "--- PREAMBLE (applies to all files below) ---\n%s\n--- END PREAMBLE ---\n\n", preamble)
+	}
+	for i, f := range files {
+		if i > 0 {
+			fmt.Fprintf(&b, "\n\n%s\n\n", separator) // Between files only, no trailing separator
+		}
+		// Add file header and content to context
+		if f.overview {
+			b.WriteString("--- PROJECT OVERVIEW (read this first) ---\n")
+		}
+		if f.root != "" {
+			fmt.Fprintf(&b, "// File: %s (from %s)\n", f.path, f.root)
+		} else {
+			fmt.Fprintf(&b, "// File: %s\n", f.path)
+		}
+		b.Write(f.content)
+	}
+	if footer != "" {
+		if b.Len() > 0 {
+			if !strings.HasSuffix(b.String(), "\n") {
+				b.WriteString("\n")
+			}
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "--- FOOTER (applies to all files above) ---\n%s\n--- END FOOTER ---", footer)
+	}
+	if style == "none" {
+		return b.String()
+	}
+	return fmt.Sprintf("--- FILE CONTEXT START ---\n%s\n--- FILE CONTEXT END ---", b.String())
+}
+
+// buildCodeMessages builds the system and user message content for the code
+// command from a context block made by formatFileContext. contextRole selects which turn carries the file context: "system"
+// (the default) or "user", for models that down-weight system prompts.
+func buildCodeMessages(contextBlock, userPrompt, contextRole string) (systemContent, userContent string) {
+	if contextRole == "user" {
+		systemContent = fmt.Sprintf(codeSystemPrompt, "in their message", "")
+		userContent = fmt.Sprintf(`%s
+
+Based on the file context above, fulfill the following request:
+
+"%s"`, contextBlock, userPrompt)
+		return systemContent, userContent
+	}
+
+	systemContent = fmt.Sprintf(codeSystemPrompt, "below", "\n\n"+contextBlock)
+	// User prompt combining context preamble and the actual request
+	userContent = fmt.Sprintf(`Based on the file context provided in the system message, fulfill the following request:
+
+"%s"`, userPrompt)
+	return systemContent, userContent
+}
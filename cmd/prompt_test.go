@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestFormatFileContextSeparators(t *testing.T) {
+	const sep = "@@SEP@@"
+	for _, style := range []string{"markers", "none"} {
+		for n := 0; n <= 4; n++ {
+			t.Run(fmt.Sprintf("%s/%d files", style, n), func(t *testing.T) {
+				var files []gatheredFile
+				for i := 0; i < n; i++ {
+					files = append(files, gatheredFile{
+						path:    fmt.Sprintf("f%d.go", i),
+						content: []byte(fmt.Sprintf("package f%d\n", i)),
+					})
+				}
+				got := formatFileContext("", files, "", style, sep)
+
+				want := n - 1
+				if n == 0 {
+					want = 0
+				}
+				if count := strings.Count(got, sep); count != want {
+					t.Errorf("got %d separators, want %d in:\n%s", count, want, got)
+				}
+				body := strings.TrimSpace(got)
+				body = strings.TrimPrefix(body, "--- FILE CONTEXT START ---")
+				body = strings.TrimSuffix(body, "--- FILE CONTEXT END ---")
+				body = strings.TrimSpace(body)
+				if strings.HasPrefix(body, sep) || strings.HasSuffix(body, sep) {
+					t.Errorf("separator leads or trails the context:\n%s", got)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Provider is a model API that gen sends the prompt to.
+type Provider interface {
+	ID() string        // Short name used to select it with --providers
+	Name() string      // Display name used in output
+	APIKeyEnv() string // Environment variable holding its API key ("" if it needs none)
+	Complete(ctx context.Context, prompt string) (string, error)
+	// Stream is Complete with the response streamed: each piece of text is
+	// passed to onDelta as it arrives, and the whole response is returned.
+	Stream(ctx context.Context, prompt string, onDelta func(string)) (string, error)
+}
+
+// genProviders is the registry gen queries, in --providers default order.
+// Adding a provider means implementing Provider and listing it here.
+var genProviders = []Provider{
+	openAIProvider{model: "gpt-4.1"},
+	openRouterProvider{id: "gemini", name: "Gemini (OpenRouter)", model: "google/gemini-2.5-pro-preview-03-25"},
+	anthropicProvider{model: "claude-3-5-sonnet-20241022"},
+	ollamaProvider{},
+}
+
+// Shared state for provider requests, set up by gen before querying
+var (
+	genSampling samplingParams // Sampling flags applied to each request body
+	genLimiter  *keyLimiter    // Serializes providers that share an API key
+)
+
+// providerIDs returns the IDs of every registered provider.
+func providerIDs() []string {
+	ids := make([]string, len(genProviders))
+	for i, p := range genProviders {
+		ids[i] = p.ID()
+	}
+	return ids
+}
+
+// defaultProviderIDs returns the IDs gen queries without --providers: every
+// registered provider except Ollama, which is added when --ollama-model names
+// a local model to run.
+func defaultProviderIDs() []string {
+	var ids []string
+	for _, id := range providerIDs() {
+		if id != "ollama" || ollamaModel != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// selectProviders returns the registered providers with the given IDs, in the order given.
+func selectProviders(ids []string) ([]Provider, error) {
+	var selected []Provider
+	for _, id := range ids {
+		found := false
+		for _, p := range genProviders {
+			if strings.EqualFold(p.ID(), strings.TrimSpace(id)) {
+				selected = append(selected, p)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown provider %q, expected one of: %s", id, strings.Join(providerIDs(), ", "))
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("--providers needs at least one provider")
+	}
+	return selected, nil
+}
+
+// providerAPIKey returns p's API key, or an error if it isn't set.
+func providerAPIKey(p Provider) (string, error) {
+	apiKey := os.Getenv(p.APIKeyEnv())
+	if apiKey == "" {
+		return "", fmt.Errorf("%s environment variable not set", p.APIKeyEnv())
+	}
+	return apiKey, nil
+}
+
+// apiKeyPages are where the keys in each API key variable are managed, shown
+// when a key is rejected.
+var apiKeyPages = map[string]string{
+	apiKeyEnvVar:        "https://openrouter.ai/settings/keys",
+	"OPENAI_API_KEY":    "https://platform.openai.com/api-keys",
+	"ANTHROPIC_API_KEY": "https://console.anthropic.com/settings/keys",
+	azureAPIKeyEnvVar:   "https://portal.azure.com (the Azure OpenAI resource's Keys and Endpoint page)",
+}
+
+// isAuthStatus reports whether an HTTP status means the API key was rejected.
+func isAuthStatus(statusCode int) bool {
+	return statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden
+}
+
+// invalidKeyError explains a 401 or 403 from service as a problem with the key
+// in keyEnv, with the API's own message (if any) and where to get a new key.
+func invalidKeyError(service, keyEnv string, statusCode int, message string) error {
+	msg := fmt.Sprintf("your %s appears invalid or expired (%s returned %d", keyEnv, service, statusCode)
+	if message != "" {
+		msg += ": " + message
+	}
+	msg += ")"
+	if page := apiKeyPages[keyEnv]; page != "" {
+		msg += "\nCheck the key, or create a new one at " + page
+	}
+	return errors.New(msg)
+}
+
+// apiErrorMessage returns the message of an {"error": {"message": ...}} body,
+// the error shape OpenRouter, OpenAI and Anthropic share, or "".
+func apiErrorMessage(body []byte) string {
+	var parsed struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	json.Unmarshal(body, &parsed) // Anything else has no message to show
+	return parsed.Error.Message
+}
+
+// bearerHeaders are the request headers for APIs that take the key as a
+// bearer token (OpenRouter and OpenAI).
+func bearerHeaders(apiKey string) map[string]string {
+	return map[string]string{
+		"Authorization": "Bearer " + apiKey,
+		"Content-Type":  "application/json",
+	}
+}
+
+// postProviderJSON sends body as JSON to url and returns the raw response body.
+// Transport errors and non-200 statuses are returned as errors.
+func postProviderJSON(ctx context.Context, p Provider, apiKey, url string, headers map[string]string, body map[string]interface{}) ([]byte, error) {
+	resp, err := sendProviderRequest(ctx, p, apiKey, url, headers, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	responseBodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	dumpRawResponse(p.Name(), responseBodyBytes)
+	return responseBodyBytes, nil
+}
+
+// postProviderStream sends body as JSON to url and passes each line of the
+// streamed reply to onLine (stopping at the first error it returns). The whole
+// stream is dumped for --debug-responses once it ends.
+func postProviderStream(ctx context.Context, p Provider, apiKey, url string, headers map[string]string, body map[string]interface{}, onLine func(line string) error) error {
+	resp, err := sendProviderRequest(ctx, p, apiKey, url, headers, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var raw bytes.Buffer
+	defer func() { dumpRawResponse(p.Name(), raw.Bytes()) }()
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(nil, 1024*1024) // Allow long events
+	for scanner.Scan() {
+		line := scanner.Text()
+		raw.WriteString(line + "\n")
+		if err := onLine(line); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read response stream: %w", err)
+	}
+	return nil
+}
+
+// sseData returns the payload of a server-sent event "data:" line, with ok
+// false for other lines (event names, comments, blanks) and the [DONE] marker.
+func sseData(line string) (data string, ok bool) {
+	data, ok = strings.CutPrefix(line, "data:")
+	data = strings.TrimSpace(data)
+	return data, ok && data != "[DONE]"
+}
+
+// releasingBody is a response body that releases a genLimiter slot when it is
+// first closed.
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// streamedResponse is what Stream returns once the stream ends: the response,
+// or an error if the stream failed or had no content.
+func streamedResponse(response string, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	if response == "" {
+		return "", fmt.Errorf("no content found in response")
+	}
+	return response, nil
+}
+
+// sendProviderRequest sends body as JSON to url and returns the response if it
+// has a 200 status. Transport errors and other statuses (with the body they
+// came with) are returned as errors. The response holds its genLimiter slot
+// until its body is closed, so a streamed reply keeps other providers sharing
+// the API key waiting until it has been read.
+func sendProviderRequest(ctx context.Context, p Provider, apiKey, url string, headers map[string]string, body map[string]interface{}) (*http.Response, error) {
+	requestBodyBytes, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request body: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(requestBodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	client := newHTTPClient(20 * time.Minute)
+	release := genLimiter.acquire(apiKey)
+	resp, err := client.Do(req)
+	if err != nil {
+		release()
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	if resp.StatusCode == http.StatusOK {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	responseBodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	dumpRawResponse(p.Name(), responseBodyBytes)
+
+	if isAuthStatus(resp.StatusCode) && p.APIKeyEnv() != "" {
+		return nil, invalidKeyError(p.Name(), p.APIKeyEnv(), resp.StatusCode, apiErrorMessage(responseBodyBytes))
+	}
+	return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(responseBodyBytes))
+}
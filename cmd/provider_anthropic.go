@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// anthropicProvider queries Claude through Anthropic's Messages API.
+type anthropicProvider struct {
+	model string
+}
+
+func (anthropicProvider) ID() string        { return "claude" }
+func (anthropicProvider) Name() string      { return "Claude" }
+func (anthropicProvider) APIKeyEnv() string { return "ANTHROPIC_API_KEY" }
+
+const anthropicMessagesURL = "https://api.anthropic.com/v1/messages"
+
+func (p anthropicProvider) requestBody(prompt string) map[string]interface{} {
+	requestBody := map[string]interface{}{
+		"model":      p.model,
+		"max_tokens": 2048,
+		"messages": []map[string]string{
+			{"role": "user", "content": prompt},
+		},
+	}
+	genSampling.applyTo(requestBody, false)
+	return requestBody
+}
+
+func anthropicHeaders(apiKey string) map[string]string {
+	return map[string]string{
+		"x-api-key":         apiKey,
+		"anthropic-version": "2023-06-01",
+		"content-type":      "application/json",
+	}
+}
+
+func (p anthropicProvider) Complete(ctx context.Context, prompt string) (string, error) {
+	apiKey, err := providerAPIKey(p)
+	if err != nil {
+		return "", err
+	}
+	body, err := postProviderJSON(ctx, p, apiKey, anthropicMessagesURL, anthropicHeaders(apiKey), p.requestBody(prompt))
+	if err != nil {
+		return "", err
+	}
+
+	var responseBody struct {
+		Content []struct {
+			Text string `json:"text"`
+		} `json:"content"`
+	}
+	if err := json.Unmarshal(body, &responseBody); err != nil {
+		return "", fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+	if len(responseBody.Content) == 0 {
+		return "", fmt.Errorf("no content found in response")
+	}
+	return responseBody.Content[0].Text, nil
+}
+
+// Stream reads the Messages API's server-sent events, taking the text deltas
+// of content_block_delta events.
+func (p anthropicProvider) Stream(ctx context.Context, prompt string, onDelta func(string)) (string, error) {
+	apiKey, err := providerAPIKey(p)
+	if err != nil {
+		return "", err
+	}
+	requestBody := p.requestBody(prompt)
+	requestBody["stream"] = true
+	var response strings.Builder
+	err = postProviderStream(ctx, p, apiKey, anthropicMessagesURL, anthropicHeaders(apiKey), requestBody, func(line string) error {
+		data, ok := sseData(line)
+		if !ok {
+			return nil
+		}
+		var event struct {
+			Type  string `json:"type"`
+			Delta struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"delta"`
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return fmt.Errorf("failed to unmarshal stream event: %w", err)
+		}
+		switch {
+		case event.Type == "error":
+			return fmt.Errorf("Anthropic API error: %s", event.Error.Message)
+		case event.Type == "content_block_delta" && event.Delta.Type == "text_delta":
+			response.WriteString(event.Delta.Text)
+			onDelta(event.Delta.Text)
+		}
+		return nil
+	})
+	return streamedResponse(response.String(), err)
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// azureOpenAIProvider queries an Azure OpenAI chat deployment. It takes the
+// place of openAIProvider when gen runs with --azure.
+type azureOpenAIProvider struct {
+	cfg azureConfig
+}
+
+func (azureOpenAIProvider) ID() string        { return "openai" }
+func (azureOpenAIProvider) Name() string      { return "Azure OpenAI" }
+func (azureOpenAIProvider) APIKeyEnv() string { return azureAPIKeyEnvVar }
+
+func (p azureOpenAIProvider) requestBody(prompt string) map[string]interface{} {
+	requestBody := map[string]interface{}{
+		"messages": []map[string]string{
+			{"role": "user", "content": prompt},
+		},
+	}
+	genSampling.applyTo(requestBody, false)
+	return requestBody
+}
+
+func (p azureOpenAIProvider) headers() map[string]string {
+	return map[string]string{
+		"api-key":      p.cfg.apiKey,
+		"Content-Type": "application/json",
+	}
+}
+
+func (p azureOpenAIProvider) Complete(ctx context.Context, prompt string) (string, error) {
+	body, err := postProviderJSON(ctx, p, p.cfg.apiKey, p.cfg.chatCompletionsURL(), p.headers(), p.requestBody(prompt))
+	if err != nil {
+		return "", err
+	}
+
+	var responseBody struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+		Error *struct {
+			Message string `json:"message"`
+			Code    string `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &responseBody); err != nil {
+		return "", fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+	if responseBody.Error != nil {
+		return "", fmt.Errorf("Azure OpenAI API error (%s): %s", responseBody.Error.Code, responseBody.Error.Message)
+	}
+	if len(responseBody.Choices) == 0 || responseBody.Choices[0].Message.Content == "" {
+		return "", fmt.Errorf("no content found in response")
+	}
+	return responseBody.Choices[0].Message.Content, nil
+}
+
+func (p azureOpenAIProvider) Stream(ctx context.Context, prompt string, onDelta func(string)) (string, error) {
+	requestBody := p.requestBody(prompt)
+	requestBody["stream"] = true
+	var response strings.Builder
+	err := postProviderStream(ctx, p, p.cfg.apiKey, p.cfg.chatCompletionsURL(), p.headers(), requestBody, func(line string) error {
+		return chatCompletionChunk(line, "Azure OpenAI", &response, onDelta)
+	})
+	return streamedResponse(response.String(), err)
+}
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+var ollamaModel string // Flag for the local Ollama model gen queries, e.g. llama3.2
+
+// defaultOllamaHost is where Ollama listens unless OLLAMA_HOST says otherwise.
+const defaultOllamaHost = "http://localhost:11434"
+
+// ollamaProvider queries a local model through Ollama's chat API. It needs no
+// API key, and is only queried by default when --ollama-model is given.
+type ollamaProvider struct{}
+
+func (ollamaProvider) ID() string        { return "ollama" }
+func (ollamaProvider) APIKeyEnv() string { return "" }
+
+func (ollamaProvider) Name() string {
+	if ollamaModel == "" {
+		return "Ollama"
+	}
+	return fmt.Sprintf("Ollama (%s)", ollamaModel)
+}
+
+// ollamaChatURL returns the /api/chat URL of the Ollama server named by
+// OLLAMA_HOST (which, as for the ollama CLI, may omit the scheme).
+func ollamaChatURL() string {
+	host := strings.TrimRight(os.Getenv("OLLAMA_HOST"), "/")
+	if host == "" {
+		host = defaultOllamaHost
+	} else if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+	return host + "/api/chat"
+}
+
+// requestBody returns the /api/chat request for prompt, streamed or not.
+func (p ollamaProvider) requestBody(prompt string, stream bool) (map[string]interface{}, error) {
+	if ollamaModel == "" {
+		return nil, fmt.Errorf("no model to run: pass --ollama-model, e.g. --ollama-model llama3.2")
+	}
+
+	// Sampling parameters go in Ollama's options rather than the top level
+	options := map[string]interface{}{}
+	genSampling.applyTo(options, true)
+	requestBody := map[string]interface{}{
+		"model": ollamaModel,
+		"messages": []map[string]string{
+			{"role": "user", "content": prompt},
+		},
+		"stream": stream,
+	}
+	if len(options) > 0 {
+		requestBody["options"] = options
+	}
+	return requestBody, nil
+}
+
+var ollamaHeaders = map[string]string{"Content-Type": "application/json"}
+
+func (p ollamaProvider) Complete(ctx context.Context, prompt string) (string, error) {
+	requestBody, err := p.requestBody(prompt, false)
+	if err != nil {
+		return "", err
+	}
+	body, err := postProviderJSON(ctx, p, "", ollamaChatURL(), ollamaHeaders, requestBody)
+	if err != nil {
+		return "", err
+	}
+	return parseOllamaChat(body)
+}
+
+// Stream reads Ollama's streamed reply, one JSON object per line.
+func (p ollamaProvider) Stream(ctx context.Context, prompt string, onDelta func(string)) (string, error) {
+	requestBody, err := p.requestBody(prompt, true)
+	if err != nil {
+		return "", err
+	}
+	var response strings.Builder
+	err = postProviderStream(ctx, p, "", ollamaChatURL(), ollamaHeaders, requestBody, func(line string) error {
+		if strings.TrimSpace(line) == "" {
+			return nil
+		}
+		var chunk ollamaChunk
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			return fmt.Errorf("failed to unmarshal stream chunk: %w", err)
+		}
+		if chunk.Error != "" {
+			return fmt.Errorf("Ollama error: %s", chunk.Error)
+		}
+		if chunk.Message.Content != "" {
+			response.WriteString(chunk.Message.Content)
+			onDelta(chunk.Message.Content)
+		}
+		return nil
+	})
+	return streamedResponse(response.String(), err)
+}
+
+// ollamaChunk is a reply to /api/chat, or one line of a streamed reply.
+type ollamaChunk struct {
+	Message struct {
+		Content string `json:"content"`
+	} `json:"message"`
+	Error string `json:"error"`
+}
+
+// parseOllamaChat extracts the reply from an /api/chat response: a single JSON
+// object, or (if the server streamed anyway) one object per line whose message
+// contents are concatenated.
+func parseOllamaChat(body []byte) (string, error) {
+	var reply strings.Builder
+	dec := json.NewDecoder(bytes.NewReader(body))
+	for {
+		var chunk ollamaChunk
+		err := dec.Decode(&chunk)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to unmarshal response body: %w", err)
+		}
+		if chunk.Error != "" {
+			return "", fmt.Errorf("Ollama error: %s", chunk.Error)
+		}
+		reply.WriteString(chunk.Message.Content)
+	}
+	if reply.Len() == 0 {
+		return "", fmt.Errorf("no content found in response")
+	}
+	return reply.String(), nil
+}
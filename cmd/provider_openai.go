@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// openAIProvider queries OpenAI's Responses API.
+type openAIProvider struct {
+	model string
+}
+
+func (openAIProvider) ID() string        { return "openai" }
+func (openAIProvider) Name() string      { return "OpenAI" }
+func (openAIProvider) APIKeyEnv() string { return "OPENAI_API_KEY" }
+
+const openAIResponsesURL = "https://api.openai.com/v1/responses"
+
+func (p openAIProvider) requestBody(prompt string) map[string]interface{} {
+	requestBody := map[string]interface{}{
+		"model": p.model,
+		"input": prompt,
+	}
+	genSampling.applyTo(requestBody, false)
+	return requestBody
+}
+
+func (p openAIProvider) Complete(ctx context.Context, prompt string) (string, error) {
+	apiKey, err := providerAPIKey(p)
+	if err != nil {
+		return "", err
+	}
+	body, err := postProviderJSON(ctx, p, apiKey, openAIResponsesURL, bearerHeaders(apiKey), p.requestBody(prompt))
+	if err != nil {
+		return "", err
+	}
+
+	// Define a struct to parse the relevant part of the response
+	var responseBody struct {
+		Output []struct {
+			Content []struct {
+				Text string `json:"text"`
+			} `json:"content"`
+		} `json:"output"`
+		Error *struct { // Check for API errors in the response body
+			Message string `json:"message"`
+			Type    string `json:"type"`
+			Code    string `json:"code"` // Code can be string or int
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &responseBody); err != nil {
+		return "", fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+	if responseBody.Error != nil {
+		return "", fmt.Errorf("OpenAI API error (%s): %s", responseBody.Error.Code, responseBody.Error.Message)
+	}
+	if len(responseBody.Output) == 0 || len(responseBody.Output[0].Content) == 0 {
+		return "", fmt.Errorf("no content found in response structure")
+	}
+	return responseBody.Output[0].Content[0].Text, nil
+}
+
+// Stream reads the Responses API's server-sent events, taking the text of
+// response.output_text.delta events.
+func (p openAIProvider) Stream(ctx context.Context, prompt string, onDelta func(string)) (string, error) {
+	apiKey, err := providerAPIKey(p)
+	if err != nil {
+		return "", err
+	}
+	requestBody := p.requestBody(prompt)
+	requestBody["stream"] = true
+	var response strings.Builder
+	err = postProviderStream(ctx, p, apiKey, openAIResponsesURL, bearerHeaders(apiKey), requestBody, func(line string) error {
+		data, ok := sseData(line)
+		if !ok {
+			return nil
+		}
+		var event struct {
+			Type     string `json:"type"`
+			Delta    string `json:"delta"`
+			Message  string `json:"message"` // Of "error" events
+			Response struct {
+				Error *struct {
+					Message string `json:"message"`
+				} `json:"error"`
+			} `json:"response"` // Of "response.failed" events
+		}
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return fmt.Errorf("failed to unmarshal stream event: %w", err)
+		}
+		switch event.Type {
+		case "response.output_text.delta":
+			response.WriteString(event.Delta)
+			onDelta(event.Delta)
+		case "error":
+			return fmt.Errorf("OpenAI API error: %s", event.Message)
+		case "response.failed":
+			if event.Response.Error != nil {
+				return fmt.Errorf("OpenAI API error: %s", event.Response.Error.Message)
+			}
+			return fmt.Errorf("OpenAI API error: the response failed")
+		}
+		return nil
+	})
+	return streamedResponse(response.String(), err)
+}
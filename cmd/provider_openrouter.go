@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// openRouterProvider queries a model through OpenRouter's chat completions API.
+type openRouterProvider struct {
+	id, name string
+	model    string // OpenRouter model slug
+}
+
+func (p openRouterProvider) ID() string      { return p.id }
+func (p openRouterProvider) Name() string    { return p.name }
+func (openRouterProvider) APIKeyEnv() string { return apiKeyEnvVar }
+
+func (p openRouterProvider) requestBody(prompt string) map[string]interface{} {
+	requestBody := map[string]interface{}{
+		"model": p.model,
+		"messages": []map[string]any{
+			{
+				"role": "user",
+				"content": []map[string]any{
+					{"type": "text", "text": prompt},
+				},
+			},
+		},
+	}
+	genSampling.applyTo(requestBody, true)
+	return requestBody
+}
+
+func (p openRouterProvider) Complete(ctx context.Context, prompt string) (string, error) {
+	apiKey, err := providerAPIKey(p)
+	if err != nil {
+		return "", err
+	}
+	body, err := postProviderJSON(ctx, p, apiKey, openRouterAPIURL, bearerHeaders(apiKey), p.requestBody(prompt))
+	if err != nil {
+		return "", err
+	}
+
+	// Parse the OpenRouter response structure
+	var responseBody struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+		Error *struct { // Check for API errors in the response body
+			Message string `json:"message"`
+			Type    string `json:"type"`
+			Code    int64  `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &responseBody); err != nil {
+		return "", fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+	if responseBody.Error != nil {
+		return "", fmt.Errorf("OpenRouter API error (%d): %s", responseBody.Error.Code, responseBody.Error.Message)
+	}
+	if len(responseBody.Choices) == 0 || responseBody.Choices[0].Message.Content == "" {
+		return "", fmt.Errorf("no content found in response")
+	}
+	return responseBody.Choices[0].Message.Content, nil
+}
+
+func (p openRouterProvider) Stream(ctx context.Context, prompt string, onDelta func(string)) (string, error) {
+	apiKey, err := providerAPIKey(p)
+	if err != nil {
+		return "", err
+	}
+	requestBody := p.requestBody(prompt)
+	requestBody["stream"] = true
+	var response strings.Builder
+	err = postProviderStream(ctx, p, apiKey, openRouterAPIURL, bearerHeaders(apiKey), requestBody, func(line string) error {
+		return chatCompletionChunk(line, "OpenRouter", &response, onDelta)
+	})
+	return streamedResponse(response.String(), err)
+}
+
+// chatCompletionChunk handles one line of a chat completions stream (the
+// format OpenRouter and Azure OpenAI share), adding its content to response.
+func chatCompletionChunk(line, service string, response *strings.Builder, onDelta func(string)) error {
+	data, ok := sseData(line)
+	if !ok {
+		return nil
+	}
+	var chunk struct {
+		Choices []struct {
+			Delta struct {
+				Content string `json:"content"`
+			} `json:"delta"`
+		} `json:"choices"`
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		return fmt.Errorf("failed to unmarshal stream chunk: %w", err)
+	}
+	if chunk.Error != nil {
+		return fmt.Errorf("%s API error: %s", service, chunk.Error.Message)
+	}
+	for _, c := range chunk.Choices {
+		if c.Delta.Content != "" {
+			response.WriteString(c.Delta.Content)
+			onDelta(c.Delta.Content)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSendProviderRequestHoldsSharedKeyUntilBodyClosed(t *testing.T) {
+	var arrived atomic.Int32
+	finish := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := arrived.Add(1)
+		fmt.Fprintf(w, "data: first part of response %d\n", n)
+		w.(http.Flusher).Flush()
+		if n == 1 {
+			<-finish // Keep the first stream open until the test reads it
+		}
+		fmt.Fprint(w, "data: [DONE]\n")
+	}))
+	defer server.Close()
+	release := sync.OnceFunc(func() { close(finish) })
+	defer release() // Before server.Close, which waits for the handler
+
+	limiter := genLimiter
+	genLimiter = newKeyLimiter([]string{"shared", "shared"}, 0)
+	t.Cleanup(func() { genLimiter = limiter })
+	send := func() (*http.Response, error) {
+		return sendProviderRequest(context.Background(), genProviders[0], "shared", server.URL, nil, map[string]interface{}{})
+	}
+
+	first, err := send()
+	if err != nil {
+		t.Fatalf("first request: %v", err)
+	}
+	secondDone := make(chan error, 1)
+	go func() {
+		resp, err := send()
+		if err == nil {
+			_, err = io.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+		secondDone <- err
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	if n := arrived.Load(); n != 1 {
+		t.Fatalf("%d requests sent while the first stream was still open, want 1", n)
+	}
+
+	release()
+	if _, err := io.ReadAll(first.Body); err != nil {
+		t.Fatal(err)
+	}
+	first.Body.Close()
+	first.Body.Close() // Closing twice must not release twice
+	select {
+	case err := <-secondDone:
+		if err != nil {
+			t.Fatalf("second request: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("second request never ran after the first body was closed")
+	}
+	if n := arrived.Load(); n != 2 {
+		t.Errorf("%d requests sent, want 2", n)
+	}
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+var readmeFirst bool // Flag to put the target's README.md at the top of the context
+
+// moveReadmeFirst puts the README.md at root (matched case-insensitively) at
+// the start of files, marked as the project overview. It is read from disk if
+// the walk didn't collect it; without one, files are returned unchanged.
+func moveReadmeFirst(files []gatheredFile, root string) []gatheredFile {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return files
+	}
+	var path string
+	for _, e := range entries {
+		if e.Type().IsRegular() && strings.EqualFold(e.Name(), "README.md") {
+			path = filepath.Join(root, e.Name())
+			break
+		}
+	}
+	if path == "" {
+		verbosef("No README.md in %s for --readme-first\n", root)
+		return files
+	}
+
+	var readme gatheredFile
+	if i := slices.IndexFunc(files, func(f gatheredFile) bool { return f.path == path }); i >= 0 {
+		readme = files[i]
+		files = slices.Delete(files, i, i+1)
+	} else {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			warnf("unreadable", "Error reading file %s: %v\n", path, err)
+			return files
+		}
+		content, ok := normalizeEncoding(content, path)
+		if !ok {
+			return files
+		}
+		content = normalizeLineEndings(content, path)
+		readme = gatheredFile{path: path, content: content}
+		if info, err := os.Stat(path); err == nil {
+			readme.modTime = info.ModTime()
+		}
+	}
+	readme.overview = true
+	verbosef("Putting %s first as the project overview\n", path)
+	return append([]gatheredFile{readme}, files...)
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// testSubject returns the base name of the file a test file covers, e.g.
+// foo.go for foo_test.go, foo.py for test_foo.py and foo.ts for foo.spec.ts.
+// ok is false if name doesn't look like a test file.
+func testSubject(name string) (subject string, ok bool) {
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	switch {
+	case ext == "":
+		return "", false
+	case strings.HasSuffix(stem, "_test") && len(stem) > len("_test"): // Go, Python
+		return strings.TrimSuffix(stem, "_test") + ext, true
+	case ext == ".py" && strings.HasPrefix(stem, "test_") && len(stem) > len("test_"):
+		return strings.TrimPrefix(stem, "test_") + ext, true
+	case strings.HasSuffix(stem, ".test") || strings.HasSuffix(stem, ".spec"): // JavaScript, TypeScript
+		inner := strings.TrimSuffix(strings.TrimSuffix(stem, ".test"), ".spec")
+		return inner + ext, inner != ""
+	case (ext == ".java" || ext == ".kt") && strings.HasSuffix(stem, "Test") && len(stem) > len("Test"):
+		return strings.TrimSuffix(stem, "Test") + ext, true
+	}
+	return "", false
+}
+
+// focusNames returns the base names of the files the request is about: the
+// --file paths and files with uncommitted git changes (including untracked
+// ones) under the root. It is computed once per walk.
+func (g *gatherer) focusNames() map[string]bool {
+	if g.focus != nil {
+		return g.focus
+	}
+	g.focus = map[string]bool{}
+	for _, f := range forcedFiles {
+		g.focus[filepath.Base(f)] = true
+	}
+	// -z keeps names with spaces or special characters whole and unquoted
+	out, err := runGit(g.opts.root, "status", "--porcelain", "-z", "--untracked-files=all", "--", ".")
+	if err != nil {
+		verbosef("--related-tests: not using changed files, git status failed (%v)\n", err)
+		return g.focus
+	}
+	entries := strings.Split(out, "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if len(entry) < 4 {
+			continue
+		}
+		g.focus[filepath.Base(entry[3:])] = true // Drop the "XY " status columns
+		if entry[0] == 'R' || entry[0] == 'C' {
+			i++ // A rename or copy is followed by its original path
+		}
+	}
+	return g.focus
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestRelatedTestsUnusualNames(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found on PATH")
+	}
+	root := writeTree(t, map[string]string{
+		"my file.go":       "package p\n",
+		"my file_test.go":  "package p // my file\n",
+		"old.go":           "package p // old\n",
+		"new name_test.go": "package p // new name\n",
+		"other.go":         "package p // other\n",
+		"other_test.go":    "package p // other test\n",
+	})
+	gitInit(t, root)
+	if err := os.WriteFile(filepath.Join(root, "my file.go"), []byte("package p\n\n// edited\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	git(t, root, "mv", "old.go", "new name.go")
+	if err := os.WriteFile(filepath.Join(root, "dé.go"), []byte("package p // dé\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "dé_test.go"), []byte("package p // dé test\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	relatedTests = true
+	t.Cleanup(func() { relatedTests = false })
+	files, _, err := gatherFiles(codeWalkOptions(root))
+	if err != nil {
+		t.Fatalf("gatherFiles: %v", err)
+	}
+	want := []string{"dé.go", "dé_test.go", "my file.go", "my file_test.go", "new name.go", "new name_test.go", "other.go"}
+	if got := gatheredNames(files); !slices.Equal(got, want) {
+		t.Errorf("gathered %v, want %v", got, want)
+	}
+}
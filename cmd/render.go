@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/glamour/styles"
+)
+
+var glamourStyle string // Flag variable for the Markdown rendering theme
+
+// glamourStyleNames returns the accepted --style values, sorted.
+func glamourStyleNames() []string {
+	names := []string{styles.AutoStyle}
+	for name := range styles.DefaultStyles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolveGlamourStyle returns the style chosen with --style, or fallback when
+// the flag wasn't given. Unknown names are rejected with the list of valid ones.
+func resolveGlamourStyle(fallback string) (string, error) {
+	if glamourStyle == "" {
+		return fallback, nil
+	}
+	if _, ok := styles.DefaultStyles[glamourStyle]; ok || glamourStyle == styles.AutoStyle {
+		return glamourStyle, nil
+	}
+	return "", fmt.Errorf("unknown --style %q, expected one of: %s", glamourStyle, strings.Join(glamourStyleNames(), ", "))
+}
+
+// newMarkdownRenderer creates a glamour renderer using the --style theme, or
+// fallback ("auto" picks dark, light or notty from the terminal) if none was chosen.
+func newMarkdownRenderer(fallback string) (*glamour.TermRenderer, error) {
+	style, err := resolveGlamourStyle(fallback)
+	if err != nil {
+		return nil, err
+	}
+	renderer, err := glamour.NewTermRenderer(glamour.WithStandardStyle(style), glamour.WithWordWrap(0))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create markdown renderer: %w", err)
+	}
+	return renderer, nil
+}
+
+// renderMarkdown renders md with renderer, falling back to the raw Markdown if
+// renderer is nil (rendering disabled) or glamour fails. Render errors are
+// reported at verbose level so they can be passed on in bug reports.
+func renderMarkdown(renderer *glamour.TermRenderer, md string) string {
+	if renderer == nil {
+		return md
+	}
+	out, err := renderer.Render(md)
+	if err != nil {
+		verbosef("Markdown rendering failed, showing raw Markdown instead: %v\n", err)
+		return md
+	}
+	return out
+}
@@ -3,16 +3,22 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
 
+var verbose bool // Flag variable for verbose output
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "vibe",
 	Short: "A simple CLI tool to vibe with your Go files",
 	Long: `Vibe is a utility designed by a distinguished engineer
 to help you quickly browse through Go source files in a directory.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return checkWarningCategories()
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -23,3 +29,12 @@ func Execute() {
 		os.Exit(1)
 	}
 }
+
+func init() {
+	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Show verbose output")
+	rootCmd.PersistentFlags().StringVar(&glamourStyle, "style", "", "Markdown rendering theme (auto, dark, light, dracula, notty, ...); defaults to auto-detect, or dark for gen")
+	rootCmd.PersistentFlags().StringVar(&proxyURL, "proxy", "", "Proxy URL for all API requests, overriding HTTPS_PROXY/HTTP_PROXY")
+	rootCmd.PersistentFlags().BoolVar(&strictConfig, "strict-config", false, "Fail instead of warning when config.json has unknown keys")
+	rootCmd.PersistentFlags().StringSliceVar(&suppressedWarnings, "suppress-warnings", nil, "Comma-separated warning categories to hide, or all: "+strings.Join(warningCategories, ", "))
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Silence informational progress output on stderr (errors and warnings are still shown)")
+}
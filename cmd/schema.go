@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+var jsonSchemaSpec string // Flag for structured output: "edits" (built-in) or a schema file
+
+// editsSchemaName is the --json-schema value selecting the built-in edits schema.
+const editsSchemaName = "edits"
+
+// editsSchema describes whole-file edits that --apply can write directly.
+const editsSchema = `{
+  "type": "object",
+  "properties": {
+    "edits": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "properties": {
+          "file": {"type": "string", "description": "Path of the file relative to the project root"},
+          "content": {"type": "string", "description": "The complete new content of the file"}
+        },
+        "required": ["file", "content"],
+        "additionalProperties": false
+      }
+    }
+  },
+  "required": ["edits"],
+  "additionalProperties": false
+}`
+
+// jsonEditsInstructions is appended to the user prompt with the built-in schema.
+const jsonEditsInstructions = `
+
+Respond with a JSON object only. List every file you create or change in "edits", with "file" set to its path relative to the project root and "content" set to the complete new content of the file.`
+
+// responseSchema is a JSON schema the model's response must conform to.
+type responseSchema struct {
+	name     string
+	doc      map[string]interface{} // Sent as OpenRouter's response_format schema
+	compiled *jsonschema.Schema
+}
+
+// loadResponseSchema loads the --json-schema value: "edits" for the built-in
+// schema, or the path of a JSON schema file.
+func loadResponseSchema(spec string) (*responseSchema, error) {
+	name, text := "vibe_edits", editsSchema
+	if spec != editsSchemaName {
+		data, err := os.ReadFile(spec)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read --json-schema file: %w", err)
+		}
+		name, text = "vibe_response", string(data)
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(text), &doc); err != nil {
+		return nil, fmt.Errorf("invalid JSON in schema %s: %w", spec, err)
+	}
+	compiled, err := jsonschema.CompileString("vibe://schemas/"+name+".json", text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON schema %s: %w", spec, err)
+	}
+	return &responseSchema{name: name, doc: doc, compiled: compiled}, nil
+}
+
+// isEdits reports whether this is the built-in edits schema.
+func (s *responseSchema) isEdits() bool {
+	return s.name == "vibe_edits"
+}
+
+// responseFormat returns OpenRouter's response_format request field.
+func (s *responseSchema) responseFormat() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "json_schema",
+		"json_schema": map[string]interface{}{
+			"name":   s.name,
+			"strict": true,
+			"schema": s.doc,
+		},
+	}
+}
+
+// validate checks that response is JSON conforming to the schema.
+func (s *responseSchema) validate(response string) error {
+	var v interface{}
+	if err := json.Unmarshal([]byte(stripJSONFence(response)), &v); err != nil {
+		return fmt.Errorf("response is not valid JSON: %w", err)
+	}
+	if err := s.compiled.Validate(v); err != nil {
+		return fmt.Errorf("response does not match --json-schema: %w", err)
+	}
+	return nil
+}
+
+// stripJSONFence removes a ```json fence some models put around JSON output
+// even when asked for a JSON object.
+func stripJSONFence(response string) string {
+	trimmed := strings.TrimSpace(response)
+	if blocks := findCodeBlocks(trimmed); len(blocks) == 1 && strings.HasPrefix(trimmed, "```") && strings.HasSuffix(trimmed, "```") {
+		return blocks[0].body
+	}
+	return trimmed
+}
+
+// parseJSONEdits decodes a response in the built-in edits schema.
+func parseJSONEdits(response string) ([]fileEdit, error) {
+	var parsed struct {
+		Edits []struct {
+			File    string `json:"file"`
+			Content string `json:"content"`
+		} `json:"edits"`
+	}
+	if err := json.Unmarshal([]byte(stripJSONFence(response)), &parsed); err != nil {
+		return nil, fmt.Errorf("failed to decode edits: %w", err)
+	}
+	edits := make([]fileEdit, 0, len(parsed.Edits))
+	for _, e := range parsed.Edits {
+		edits = append(edits, fileEdit{path: e.File, content: e.Content})
+	}
+	return edits, nil
+}
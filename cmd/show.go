@@ -1,19 +1,28 @@
 package cmd
 
 import (
+	"bytes"
 	"fmt"
-	"io/fs"
-	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/glamour/styles"
 	"github.com/spf13/cobra"
 )
 
 var (
-	showUnfiltered bool // Flag variable for unfiltered listing
-	noRecursive    bool // Flag variable for non-recursive traversal
-	verbose        bool // Flag variable for verbose output
+	showUnfiltered   bool   // Flag variable for unfiltered listing
+	noRecursive      bool   // Flag variable for non-recursive traversal
+	showSeparator    string // Flag variable for the line printed between files
+	noSeparator      bool   // Flag variable to omit separators entirely
+	commentSeparator bool   // Flag variable to write separators as comments in each file's language
+	renderShow       bool   // Flag variable to render files as syntax-highlighted Markdown
+	headLines        int    // Flag variable to show only the first N lines of each file
+	tailLines        int    // Flag variable to show only the last N lines of each file
+	pathsOnly        bool   // Flag variable to list the matched files without their contents
+	absolutePaths    bool   // Flag variable to list absolute paths with --paths-only
+	langMapEntries   map[string]string
 )
 
 // showCmd represents the show command
@@ -26,99 +35,241 @@ For each file found, it prints the absolute file path followed by the file's con
 By default, it filters out certain files (e.g., _test.go, go.mod, go.sum).
 Use the -u flag to show all files unfiltered.
 Use the -n flag to only show files in the specified directory without going into subdirectories.
-Use the -v flag to show verbose output.`,
+Use the -v flag to show verbose output.
+
+Files are separated by a "---" line. Use --separator to change it, --no-separator
+to omit it, or --comment-separator to write the separator and "File:" headers as
+comments in each file's language (e.g. "// ---" for Go, "# ---" for Python) so the
+output of a single-language tree can still be compiled or linted. Files with an
+unknown comment syntax fall back to the plain separator.
+
+Use --format to choose the output: text (the default, as described above),
+markdown to syntax-highlight each file in the terminal, or json for a
+{"directory", "files": [{"path", "content"}]} object that scripts can read.
+--render is the older spelling of --format markdown and still works.
+
+With --format markdown, each file is syntax-highlighted in the terminal. The language is taken
+from the file extension, from the file name for common extensionless files
+(Dockerfile, Makefile, Jenkinsfile, ...), or from the #! line of extensionless
+scripts. Extend or override the mapping with
+--lang-map, e.g. --lang-map Justfile=makefile,.tpl=html. The theme follows the
+terminal background unless --style is given.
+
+Use --paths-only to check what the filters select without printing contents:
+each matching file's path (relative to the directory, or absolute with
+--absolute) is printed on its own line, ready for | wc -l.
+
+The directory may also be a .zip, .tar.gz or .tgz archive: it is extracted to a
+temporary directory (removed afterwards) and shown with the same filters.
+
+Use --head N or --tail N to skim large files: only the first or last N lines of
+each file are shown, with a "... (M more lines)" note where the rest was cut.
+
+Use --grep REGEX to only show the files that mention something, e.g.
+--grep 'func \(s \*Server\)'; add -i to ignore case. It combines with the
+other filters and with --paths-only, and on a terminal the matches are
+highlighted in text output.`,
 	Args: cobra.ExactArgs(1), // Requires exactly one argument: the directory
 	RunE: func(cmd *cobra.Command, args []string) error {
 		targetDir := args[0]
+		if headLines < 0 || tailLines < 0 {
+			return fmt.Errorf("--head and --tail must not be negative")
+		}
 
-		// Get absolute path for consistent output and checking
-		absTargetDir, err := filepath.Abs(targetDir)
+		if isArchive(targetDir) {
+			dir, cleanup, err := extractArchive(targetDir)
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+			targetDir = dir
+		}
+
+		// Get absolute path and check that the target directory exists
+		absTargetDir, err := resolveTargetDir(targetDir)
 		if err != nil {
-			return fmt.Errorf("failed to get absolute path for %s: %w", targetDir, err)
+			return err
 		}
 
-		// Check if the target directory exists and is a directory
-		info, err := os.Stat(absTargetDir)
+		if renderShow && !cmd.Flags().Changed("format") {
+			showFormat = formatMarkdown // --render predates --format
+		}
+
+		grep, err := compileShowGrep()
 		if err != nil {
-			if os.IsNotExist(err) {
-				return fmt.Errorf("directory not found: %s", absTargetDir)
+			return err
+		}
+
+		langOverrides = parseLangMap(langMapEntries)
+		var renderer *glamour.TermRenderer
+		if showFormat == formatMarkdown {
+			renderer, err = newMarkdownRenderer(styles.AutoStyle)
+			if err != nil {
+				return err
 			}
-			return fmt.Errorf("failed to stat %s: %w", absTargetDir, err)
 		}
-		if !info.IsDir() {
-			return fmt.Errorf("path is not a directory: %s", absTargetDir)
+
+		// Walk the directory
+		files, _, walkErr := gatherFiles(walkOptions{
+			root:        absTargetDir,
+			noRecursive: noRecursive,
+			keepLocks:   showUnfiltered, // -u shows everything
+			skipDir:     showSkipDir,
+			includeFile: showIncludeFile,
+		})
+		if walkErr != nil {
+			// Handle error returned by WalkDir itself
+			return fmt.Errorf("error walking the path %q: %w", absTargetDir, walkErr)
+		}
+		if grep != nil {
+			files = grepFiles(files, grep)
 		}
 
-		fmt.Printf("Traversing directory: %s\n", absTargetDir)
+		if showFormat == formatJSON {
+			return printShowJSON(absTargetDir, files)
+		}
+		if pathsOnly {
+			for _, f := range files {
+				fmt.Println(listedPath(absTargetDir, f.path))
+			}
+			return nil
+		}
+
+		fmt.Println(showDecorate(firstPath(files), "Traversing directory: "+absTargetDir))
 		if !showUnfiltered && verbose {
-			fmt.Println("Filtering out test, mod, sum, LICENSE, hidden, and markdown files. Use -u to show all.")
+			fmt.Println(showDecorate(firstPath(files), "Filtering out test, mod, sum, LICENSE, hidden, and markdown files. Use -u to show all."))
 		}
 		if noRecursive && verbose {
-			fmt.Println("Non-recursive mode: only showing files in the specified directory.")
+			fmt.Println(showDecorate(firstPath(files), "Non-recursive mode: only showing files in the specified directory."))
 		}
-		fmt.Println("---") // Separator
+		printShowSeparator(firstPath(files)) // Separator
 
-		// Walk the directory
-		walkErr := filepath.WalkDir(absTargetDir, func(path string, d fs.DirEntry, err error) error {
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error accessing path %q: %v\n", path, err)
-				return nil // Continue walking if possible
+		for _, f := range files {
+			content := selectLines(f.content, headLines, tailLines)
+			if grep != nil && highlightGrep() {
+				content = highlightMatches(content, grep)
 			}
-
-			// Skip directories
-			if d.IsDir() {
-				// If in non-recursive mode, skip all subdirectories
-				if noRecursive && path != absTargetDir {
-					return filepath.SkipDir
-				}
-
-				dirName := d.Name()
-				if dirName == ".git" || dirName == "vendor" || strings.HasPrefix(dirName, ".") ||
-					dirName == "node_modules" || dirName == "__pycache__" || dirName == "target" ||
-					dirName == "build" || dirName == "dist" {
-					return filepath.SkipDir
-				}
-				return nil
+			if renderer != nil {
+				md := fmt.Sprintf("**File: %s**\n\n%s\n", f.path, fencedCodeBlock(languageForContent(f.path, f.content), string(content)))
+				fmt.Print(renderMarkdown(renderer, md))
+			} else {
+				// Output plain text format
+				fmt.Printf("%s\n\n%s\n", showDecorate(f.path, "File: "+f.path), string(content))
 			}
+			printShowSeparator(f.path) // Separator between files
+		}
 
-			// Filtering Logic
-			fileName := d.Name()
-			if !showUnfiltered {
-				if strings.HasSuffix(fileName, "_test.go") ||
-					fileName == "go.mod" || fileName == "go.sum" ||
-					fileName == "LICENSE" || strings.HasSuffix(fileName, ".md") ||
-					strings.HasPrefix(fileName, ".") {
-					return nil // Skip filtered file
-				}
-			}
+		return nil // Success
+	},
+}
 
-			// --- Process File ---
-			absPath, err := filepath.Abs(path)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Warning: could not get absolute path for %s: %v\n", path, err)
-				absPath = path // Fallback
-			}
+// showJSONFile is one file in show --format json output. Content is left out
+// with --paths-only.
+type showJSONFile struct {
+	Path    string  `json:"path"`
+	Content *string `json:"content,omitempty"`
+}
 
-			content, err := os.ReadFile(path)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error reading file %s: %v\n", path, err)
-				return nil // Continue walking
-			}
+// printShowJSON prints the files as a JSON object with the directory shown
+// and each file's path and content (after --head or --tail), for scripts.
+func printShowJSON(root string, files []gatheredFile) error {
+	out := struct {
+		Directory string         `json:"directory"`
+		Files     []showJSONFile `json:"files"`
+	}{Directory: root, Files: []showJSONFile{}}
+	for _, f := range files {
+		if pathsOnly {
+			out.Files = append(out.Files, showJSONFile{Path: listedPath(root, f.path)})
+			continue
+		}
+		content := string(selectLines(f.content, headLines, tailLines))
+		out.Files = append(out.Files, showJSONFile{Path: f.path, Content: &content})
+	}
+	return printJSON(out)
+}
 
-			// Output plain text format
-			fmt.Printf("File: %s\n\n%s\n", absPath, string(content))
-			fmt.Println("---") // Separator between files
+// showDecorate formats an informational line for show's output, turning it into
+// a comment in path's language when --comment-separator is set.
+func showDecorate(path, line string) string {
+	if commentSeparator {
+		if style, ok := commentStyleFor(path); ok {
+			return style.wrap(line)
+		}
+	}
+	return line
+}
 
-			return nil // Continue walking
-		})
+// printShowSeparator prints the separator that follows the file at path.
+func printShowSeparator(path string) {
+	if noSeparator {
+		return
+	}
+	fmt.Println(showDecorate(path, showSeparator))
+}
 
-		if walkErr != nil {
-			// Handle error returned by WalkDir itself
-			return fmt.Errorf("error walking the path %q: %w", absTargetDir, walkErr)
+// selectLines keeps the first head lines of content, or the last tail lines if
+// head is 0, noting how many lines were left out. Zero for both (or a file that
+// is short enough) returns content unchanged.
+func selectLines(content []byte, head, tail int) []byte {
+	n := head
+	if n == 0 {
+		n = tail
+	}
+	lines := bytes.SplitAfter(content, []byte("\n"))
+	if len(lines) > 0 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1] // SplitAfter leaves an empty element after a final newline
+	}
+	if n == 0 || len(lines) <= n {
+		return content
+	}
+	note := []byte(fmt.Sprintf("... (%d more lines)\n", len(lines)-n))
+	if head > 0 {
+		kept := bytes.Join(lines[:n], nil)
+		if !bytes.HasSuffix(kept, []byte("\n")) {
+			kept = append(kept, '\n')
 		}
+		return append(kept, note...)
+	}
+	return append(note, bytes.Join(lines[len(lines)-n:], nil)...)
+}
 
-		return nil // Success
-	},
+// listedPath returns how --paths-only prints path: relative to root unless
+// --absolute is set or the file (a --file) lies outside root.
+func listedPath(root, path string) string {
+	if absolutePaths {
+		return path
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+	return rel
+}
+
+// firstPath returns the path of the first file, or "" if there are none.
+func firstPath(files []gatheredFile) string {
+	if len(files) == 0 {
+		return ""
+	}
+	return files[0].path
+}
+
+// showSkipDir reports whether show should skip a directory.
+func showSkipDir(dirName string) bool {
+	return dirName == ".git" || dirName == "vendor" || strings.HasPrefix(dirName, ".") ||
+		dirName == "node_modules" || dirName == "__pycache__" || dirName == "target" ||
+		dirName == "build" || dirName == "dist"
+}
+
+// showIncludeFile reports whether show should display a file.
+func showIncludeFile(fileName string) bool {
+	if showUnfiltered {
+		return true
+	}
+	return !(strings.HasSuffix(fileName, "_test.go") ||
+		fileName == "go.mod" || fileName == "go.sum" ||
+		fileName == "LICENSE" || strings.HasSuffix(fileName, ".md") ||
+		strings.HasPrefix(fileName, "."))
 }
 
 func init() {
@@ -127,4 +278,20 @@ func init() {
 	// Define flags for the show command
 	showCmd.Flags().BoolVarP(&showUnfiltered, "unfiltered", "u", false, "Show all files, including normally filtered ones")
 	showCmd.Flags().BoolVarP(&noRecursive, "no-recursive", "n", false, "Only show files in the specified directory without going into subdirectories")
+	showCmd.Flags().StringVar(&showSeparator, "separator", "---", "Line printed between files")
+	showCmd.Flags().BoolVar(&noSeparator, "no-separator", false, "Don't print separators between files")
+	showCmd.Flags().BoolVar(&commentSeparator, "comment-separator", false, "Write separators and file headers as comments in each file's language")
+	showCmd.Flags().BoolVar(&renderShow, "render", false, "Render each file as syntax-highlighted Markdown")
+	showCmd.Flags().MarkDeprecated("render", "use --format markdown instead")
+	showCmd.Flags().Var(&showFormat, "format", "Output format: text, markdown (syntax-highlighted) or json")
+	showCmd.MarkFlagsMutuallyExclusive("render", "format")
+	showCmd.Flags().StringToStringVar(&langMapEntries, "lang-map", nil, "Extra file name/extension to language mappings for highlighting, e.g. Justfile=makefile,.tpl=html")
+	showCmd.Flags().IntVar(&headLines, "head", 0, "Only show the first N lines of each file")
+	showCmd.Flags().IntVar(&tailLines, "tail", 0, "Only show the last N lines of each file")
+	showCmd.MarkFlagsMutuallyExclusive("head", "tail")
+	showCmd.Flags().BoolVar(&pathsOnly, "paths-only", false, "Only list the paths of the files that pass the filters, one per line")
+	showCmd.Flags().BoolVar(&absolutePaths, "absolute", false, "With --paths-only, print absolute paths")
+	showCmd.Flags().StringVar(&showGrep, "grep", "", "Only show files whose content matches this regular expression (matches are highlighted on a terminal)")
+	showCmd.Flags().BoolVarP(&showIgnoreCase, "ignore-case", "i", false, "Match --grep case-insensitively")
+	addWalkFlags(showCmd)
 }
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/cobra"
+)
+
+const templateExt = ".tmpl"
+
+// templatesDir returns the directory prompt templates are loaded from.
+func templatesDir() (string, error) {
+	dir, err := vibeConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "templates"), nil
+}
+
+// renderPromptTemplate loads the named template and executes it with the prompt,
+// target directory and --var values. Referencing an undefined variable is an error.
+func renderPromptTemplate(name, prompt, dir string, vars map[string]string) (string, error) {
+	tmplDir, err := templatesDir()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(tmplDir, strings.TrimSuffix(name, templateExt)+templateExt)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("template %q not found in %s (see 'vibe template list')", name, tmplDir)
+		}
+		return "", fmt.Errorf("failed to read template %s: %w", path, err)
+	}
+
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(string(content))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template %s: %w", path, err)
+	}
+	data := map[string]string{}
+	for k, v := range vars {
+		data[k] = v
+	}
+	data["Prompt"] = prompt
+	data["Dir"] = dir
+
+	var out strings.Builder
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("failed to render template %q (set missing values with --var key=value): %w", name, err)
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// templateCmd represents the template command
+var templateCmd = &cobra.Command{
+	Use:   "template",
+	Short: "Manage prompt templates used by 'vibe code --template'",
+	Long: `Prompt templates are Go text/template files stored as
+~/.config/vibe/templates/<name>.tmpl. They can reference {{.Prompt}} (the prompt
+argument), {{.Dir}} (the absolute target directory) and any value passed with
+--var key=value as {{.key}}.
+
+Example template (add-tests.tmpl):
+  Add table-driven tests for the {{.pkg}} package in {{.Dir}}. {{.Prompt}}
+
+Usage:
+  vibe code --template add-tests --var pkg=parser "" ./parser`,
+}
+
+// templateListCmd represents the template list command
+var templateListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List available prompt templates",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := templatesDir()
+		if err != nil {
+			return err
+		}
+		matches, err := filepath.Glob(filepath.Join(dir, "*"+templateExt))
+		if err != nil {
+			return fmt.Errorf("failed to list templates: %w", err)
+		}
+		if len(matches) == 0 {
+			logf("No templates found in %s\n", dir)
+			return nil
+		}
+		sort.Strings(matches)
+		for _, m := range matches {
+			name := strings.TrimSuffix(filepath.Base(m), templateExt)
+			fmt.Printf("%-20s %s\n", name, templateSummary(m))
+		}
+		return nil
+	},
+}
+
+// templateSummary returns the first non-empty line of a template file.
+func templateSummary(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+func init() {
+	rootCmd.AddCommand(templateCmd)
+	templateCmd.AddCommand(templateListCmd)
+}
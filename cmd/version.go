@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/daviddl9/vibe/internal/version"
+	"github.com/spf13/cobra"
+)
+
+// versionCmd represents the version command
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print the vibe version and build metadata",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Print(versionString())
+	},
+}
+
+// versionString formats the build metadata injected via ldflags.
+func versionString() string {
+	return fmt.Sprintf("vibe version %s\ncommit: %s\nbuilt:  %s\n",
+		version.Version, version.GitCommit, version.GitCommitDate)
+}
+
+func init() {
+	rootCmd.AddCommand(versionCmd)
+
+	// Enable `vibe --version` with the same output as `vibe version`
+	rootCmd.Version = version.Version
+	rootCmd.SetVersionTemplate(versionString())
+}
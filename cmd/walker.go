@@ -0,0 +1,500 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/spf13/cobra"
+)
+
+// --- Variables for shared walker flags ---
+var (
+	followSymlinks bool         // Flag to descend into symlinked directories
+	minFileSize    byteSize     // Flag to skip files smaller than this size
+	forcedFiles    []string     // Flag for files to include regardless of filters
+	maxDepth       int          // Flag for how many directory levels below the root to descend (-1 = unlimited)
+	fileOrder      contextOrder // Flag for the order gathered files are returned in
+	inclLockfiles  bool         // Flag to stop skipping dependency lockfiles
+	includeEmpty   bool         // Flag to collect zero-byte files
+	keepCRLF       bool         // Flag to leave CRLF line endings as they are in file contents
+	maxFileLines   int          // Flag to truncate each file to this many lines (0 = no limit)
+	relatedTests   bool         // Flag to only collect test files whose subject is focused or changed
+
+	contextGlobs []string // Flag for doublestar globs that replace the command's file filters
+	excludeGlobs []string // Flag for doublestar globs of files to leave out
+
+	staleAge time.Duration // Flag for how much older than the newest file a file may be before a warning (0 = off)
+)
+
+// lockfileNames are dependency lockfiles: large, generated, and of no use to a
+// model, so every command skips them unless --include-lockfiles is given.
+// Keys are lowercase, and names are matched ignoring case.
+var lockfileNames = map[string]bool{
+	"go.sum":              true,
+	"package-lock.json":   true,
+	"npm-shrinkwrap.json": true,
+	"yarn.lock":           true,
+	"pnpm-lock.yaml":      true,
+	"bun.lockb":           true,
+	"cargo.lock":          true,
+	"poetry.lock":         true,
+	"pipfile.lock":        true,
+	"uv.lock":             true,
+	"composer.lock":       true,
+	"gemfile.lock":        true,
+	"podfile.lock":        true,
+	"pubspec.lock":        true,
+	"mix.lock":            true,
+	"flake.lock":          true,
+	"packages.lock.json":  true,
+	"gradle.lockfile":     true,
+}
+
+// walkOptions controls how gatherFiles traverses a directory tree.
+// Each command supplies its own directory and file filters.
+type walkOptions struct {
+	root        string   // Absolute path of the directory to walk
+	extraRoots  []string // More directories walked after root (code's --dir)
+	noRecursive bool     // Only collect files directly inside root
+	maxFileSize int64    // Skip files larger than this many bytes (0 = no limit)
+	keepLocks   bool     // Collect lockfiles even without --include-lockfiles
+	skipEmpty   bool     // Skip empty files unless --include-empty is set
+	dedup       bool     // Skip files whose content matches one already collected
+	normalize   bool     // Decode non-UTF-8 encodings and convert CRLF line endings
+
+	skipDir     func(name string) bool // Reports whether a directory should be pruned
+	includeFile func(name string) bool // Reports whether a file should be collected
+}
+
+// gatheredFile is a single file collected by gatherFiles.
+type gatheredFile struct {
+	path    string // Absolute path as seen under the root (symlinks are not resolved)
+	content []byte
+	modTime time.Time
+
+	overview bool   // The project README placed first by --readme-first
+	root     string // With extra roots, the root the file was gathered under
+}
+
+// walkStats summarises what gatherFiles left out.
+type walkStats struct {
+	skippedDirs int
+	duplicates  int
+}
+
+// gatherer holds the state of a single gatherFiles call.
+type gatherer struct {
+	opts  walkOptions
+	root  string // The root being walked: opts.root, then each extra root
+	files []gatheredFile
+	stats walkStats
+	slots *fileSlots // Bounds the files open at once (--max-open-files)
+
+	seenPaths   map[string]string   // Resolved path -> first path it was collected as
+	seenHashes  map[[32]byte]string // Content hash -> first path with that content
+	visitedDirs map[string]bool     // Resolved directories already walked (loop guard)
+	focus       map[string]bool     // Base names of focused/changed files, for --related-tests
+
+	includeGlobs []string // --context-glob and allowlist patterns
+	excludeGlobs []string // --exclude-glob and denylist patterns
+}
+
+// gatherFiles walks opts.root (and any extra roots) and returns the content of every file that passes
+// the filters, in lexical order unless --context-order says otherwise. Files reached through several paths (symlinks
+// or overlapping roots) or with identical content are only collected once.
+func gatherFiles(opts walkOptions) ([]gatheredFile, walkStats, error) {
+	if maxOpenFiles < 0 {
+		return nil, walkStats{}, fmt.Errorf("invalid --max-open-files %d: must be 0 (no limit) or more", maxOpenFiles)
+	}
+	include, exclude, err := filterGlobs(opts.root)
+	if err != nil {
+		return nil, walkStats{}, err
+	}
+	for _, pattern := range append(append([]string{}, include...), exclude...) {
+		if !doublestar.ValidatePattern(pattern) {
+			return nil, walkStats{}, fmt.Errorf("invalid glob %q", pattern)
+		}
+	}
+	g := &gatherer{
+		opts:         opts,
+		slots:        newFileSlots(maxOpenFiles),
+		seenPaths:    map[string]string{},
+		seenHashes:   map[[32]byte]string{},
+		visitedDirs:  map[string]bool{},
+		includeGlobs: include,
+		excludeGlobs: exclude,
+	}
+	for _, root := range append([]string{opts.root}, opts.extraRoots...) {
+		g.root = root
+		if err := g.walk(root, root); err != nil {
+			return g.files, g.stats, err
+		}
+	}
+	g.root = opts.root
+	if err := g.addForcedFiles(); err != nil {
+		return g.files, g.stats, err
+	}
+	fileOrder.sortFiles(g.files)
+	if staleAge > 0 {
+		warnStaleFiles(g.files, staleAge)
+	}
+	return g.files, g.stats, nil
+}
+
+// warnStaleFiles warns about files last modified more than maxAge before the
+// newest gathered file, which often means a forgotten generated artifact.
+func warnStaleFiles(files []gatheredFile, maxAge time.Duration) {
+	var newest time.Time
+	for _, f := range files {
+		if f.modTime.After(newest) {
+			newest = f.modTime
+		}
+	}
+	for _, f := range files {
+		if f.modTime.IsZero() {
+			continue
+		}
+		if age := newest.Sub(f.modTime); age > maxAge {
+			warnf("stale", "%s is %.0f days older than the newest file; it may be stale or generated\n", f.path, age.Hours()/24)
+		}
+	}
+}
+
+// addForcedFiles collects the --file paths (relative to the root unless absolute),
+// bypassing the command's filters but not deduplication.
+func (g *gatherer) addForcedFiles() error {
+	for _, f := range forcedFiles {
+		path := f
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(g.opts.root, path)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("--file %s: %w", f, err)
+		}
+		if info.IsDir() {
+			return fmt.Errorf("--file %s: is a directory", f)
+		}
+		g.collect(path, filepath.Clean(path))
+	}
+	return nil
+}
+
+// walk traverses realDir, reporting paths as if it were mounted at displayDir.
+// The two differ only when walking the target of a followed symlink.
+func (g *gatherer) walk(realDir, displayDir string) error {
+	return filepath.WalkDir(realDir, func(path string, d fs.DirEntry, walkErr error) error {
+		displayPath := displayDir
+		if rel, err := filepath.Rel(realDir, path); err == nil && rel != "." {
+			displayPath = filepath.Join(displayDir, rel)
+		}
+
+		if walkErr != nil {
+			warnf("unreadable", "Error accessing path %q: %v\n", displayPath, walkErr)
+			if d != nil && d.IsDir() {
+				return filepath.SkipDir // Skip directory if error accessing it
+			}
+			return nil // Attempt to continue if it was a file error
+		}
+
+		if d.IsDir() {
+			if path != realDir && (g.opts.noRecursive || g.tooDeep(displayPath) || g.opts.skipDir(d.Name())) {
+				g.stats.skippedDirs++
+				return filepath.SkipDir
+			}
+			if followSymlinks && !g.enterDir(path, displayPath) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if d.Type()&fs.ModeSymlink != 0 {
+			return g.visitSymlink(path, displayPath, d.Name())
+		}
+
+		g.visitFile(path, displayPath, d.Name())
+		return nil
+	})
+}
+
+// tooDeep reports whether the directory at displayPath is more than --max-depth
+// levels below the root (with --max-depth 0 only top-level files are collected).
+func (g *gatherer) tooDeep(displayPath string) bool {
+	if maxDepth < 0 {
+		return false
+	}
+	rel, err := filepath.Rel(g.root, displayPath)
+	if err != nil {
+		return false
+	}
+	return len(strings.Split(rel, string(filepath.Separator))) > maxDepth
+}
+
+// enterDir records a directory as visited, returning false if its resolved
+// path has already been walked (i.e. a symlink loop).
+func (g *gatherer) enterDir(path, displayPath string) bool {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		resolved = path
+	}
+	if g.visitedDirs[resolved] {
+		verbosef("Skipping %s: already walked %s (symlink loop or duplicate link)\n", displayPath, resolved)
+		return false
+	}
+	g.visitedDirs[resolved] = true
+	return true
+}
+
+// visitSymlink handles a symlink entry. Links to files are collected like any
+// other file; links to directories are only walked when --follow-symlinks is set.
+func (g *gatherer) visitSymlink(path, displayPath, name string) error {
+	target, err := os.Stat(path)
+	if err != nil {
+		warnf("symlink", "Skipping broken symlink %s: %v\n", displayPath, err)
+		return nil
+	}
+	if !target.IsDir() {
+		g.visitFile(path, displayPath, name)
+		return nil
+	}
+	if !followSymlinks || g.opts.noRecursive || g.tooDeep(displayPath) || g.opts.skipDir(name) {
+		return nil
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		warnf("symlink", "Could not resolve symlink %s: %v\n", displayPath, err)
+		return nil
+	}
+	return g.walk(resolved, displayPath)
+}
+
+// visitFile applies the file filters and collects the file if it is not a duplicate.
+func (g *gatherer) visitFile(path, displayPath, name string) {
+	rel := g.relPath(displayPath)
+	lockfile := lockfileNames[strings.ToLower(name)] // Collected with --include-lockfiles even when .lock or .sum files otherwise aren't
+	if pattern, ok := matchGlob(g.excludeGlobs, rel); ok {
+		verbosef("Skipping %s: matches exclude glob %s\n", displayPath, pattern)
+		return
+	}
+	if len(g.includeGlobs) > 0 {
+		if _, ok := matchGlob(g.includeGlobs, rel); !ok {
+			return
+		}
+	} else if !g.opts.includeFile(name) && !(lockfile && inclLockfiles) && !g.isScript(path, displayPath, name) {
+		return
+	}
+	if subject, ok := testSubject(name); ok && relatedTests && !g.focusNames()[subject] {
+		verbosef("Skipping %s: %s is neither a --file nor changed (--related-tests)\n", displayPath, subject)
+		return
+	}
+	if lockfile && !inclLockfiles && !g.opts.keepLocks {
+		verbosef("Skipping lockfile %s (use --include-lockfiles to keep it)\n", displayPath)
+		return
+	}
+	if excludeGenerated {
+		release := g.slots.acquire()
+		generated, why := isGenerated(path, name)
+		release()
+		if generated {
+			verbosef("Skipping generated file %s: %s\n", displayPath, why)
+			return
+		}
+	}
+
+	if g.opts.maxFileSize > 0 || minFileSize > 0 || (g.opts.skipEmpty && !includeEmpty) {
+		info, statErr := os.Stat(path)
+		if statErr == nil {
+			// Avoid reading excessively large files
+			if g.opts.maxFileSize > 0 && info.Size() > g.opts.maxFileSize {
+				warnf("large", "Skipping large file %s (>%dMB)\n", displayPath, g.opts.maxFileSize/(1024*1024))
+				return
+			}
+			// Empty placeholders would only add a header with nothing under it
+			if info.Size() == 0 && g.opts.skipEmpty && !includeEmpty {
+				verbosef("Skipping empty file %s (use --include-empty to keep it)\n", displayPath)
+				return
+			}
+			// Skip tiny boilerplate files (one-line doc.go, __init__.py, ...)
+			if info.Size() < int64(minFileSize) {
+				verbosef("Skipping %s: smaller than --min-file-size (%d bytes)\n", displayPath, info.Size())
+				return
+			}
+		}
+	}
+
+	g.collect(path, displayPath)
+}
+
+// relPath returns displayPath relative to the walk root with forward slashes,
+// the form --context-glob and --exclude-glob patterns are matched against.
+func (g *gatherer) relPath(displayPath string) string {
+	rel, err := filepath.Rel(g.root, displayPath)
+	if err != nil {
+		rel = displayPath
+	}
+	return filepath.ToSlash(rel)
+}
+
+// matchGlob returns the first of patterns that matches rel.
+func matchGlob(patterns []string, rel string) (string, bool) {
+	for _, p := range patterns {
+		if ok, _ := doublestar.Match(p, rel); ok { // Patterns were validated by gatherFiles
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// isScript reports whether an extensionless file starts with the shebang of a
+// known scripting language, so it is collected even though the command's
+// extension-based filters would drop it.
+func (g *gatherer) isScript(path, displayPath, name string) bool {
+	if filepath.Ext(name) != "" || strings.HasPrefix(name, ".") {
+		return false
+	}
+	release := g.slots.acquire()
+	defer release()
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	head := make([]byte, 256) // Enough for any reasonable #! line
+	n, _ := io.ReadFull(f, head)
+	lang := shebangLanguage(head[:n])
+	if lang == "" {
+		return false
+	}
+	verbosef("Including %s: %s shebang\n", displayPath, lang)
+	return true
+}
+
+// collect reads a file that passed the filters and adds it unless it duplicates
+// one already collected.
+func (g *gatherer) collect(path, displayPath string) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		resolved = path
+	}
+	if first, ok := g.seenPaths[resolved]; ok {
+		if first != displayPath { // A --file the walk already collected isn't worth mentioning
+			g.stats.duplicates++
+			verbosef("Skipping %s: same file as %s\n", displayPath, first)
+		}
+		return
+	}
+	g.seenPaths[resolved] = displayPath
+
+	release := g.slots.acquire()
+	content, readErr := os.ReadFile(path)
+	release()
+	if readErr != nil {
+		warnf("unreadable", "Error reading file %s: %v\n", displayPath, readErr)
+		return
+	}
+	if g.opts.normalize {
+		var ok bool
+		content, ok = normalizeEncoding(content, displayPath)
+		if !ok {
+			return
+		}
+		content = normalizeLineEndings(content, displayPath)
+	}
+
+	// Empty files all hash the same, so only dedup files with content
+	if g.opts.dedup && len(content) > 0 {
+		hash := sha256.Sum256(content)
+		if first, ok := g.seenHashes[hash]; ok {
+			g.stats.duplicates++
+			verbosef("Skipping %s: identical content to %s\n", displayPath, first)
+			return
+		}
+		g.seenHashes[hash] = displayPath
+	}
+
+	if maxFileLines > 0 {
+		content = truncateLines(content, maxFileLines, displayPath)
+	}
+
+	var modTime time.Time
+	if info, err := os.Stat(path); err == nil {
+		modTime = info.ModTime()
+	}
+	f := gatheredFile{path: displayPath, content: content, modTime: modTime}
+	if len(g.opts.extraRoots) > 0 {
+		f.root = g.root
+	}
+	g.files = append(g.files, f)
+}
+
+// truncatedMarker replaces the lines cut by --max-file-lines.
+const truncatedMarker = "... [truncated] ...\n"
+
+// truncateLines keeps the first n lines of content, marking the cut so the
+// model knows the file continues.
+func truncateLines(content []byte, n int, displayPath string) []byte {
+	cut := 0
+	for i := 0; i < n; i++ {
+		next := bytes.IndexByte(content[cut:], '\n')
+		if next < 0 {
+			return content // Fewer than n lines
+		}
+		cut += next + 1
+	}
+	if cut == len(content) {
+		return content
+	}
+	verbosef("Truncating %s to %d lines (--max-file-lines)\n", displayPath, n)
+	truncated := append([]byte{}, content[:cut]...)
+	return append(truncated, truncatedMarker...)
+}
+
+// resolveTargetDir returns the absolute path of targetDir, checking that it
+// exists and is a directory.
+func resolveTargetDir(targetDir string) (string, error) {
+	absTargetDir, err := filepath.Abs(targetDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path for %s: %w", targetDir, err)
+	}
+	info, err := os.Stat(absTargetDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("directory not found: %s", absTargetDir)
+		}
+		return "", fmt.Errorf("failed to stat %s: %w", absTargetDir, err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("path is not a directory: %s", absTargetDir)
+	}
+	return absTargetDir, nil
+}
+
+// addWalkFlags registers the directory-walking flags shared by the
+// context-gathering commands (show, code, gemini).
+func addWalkFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&followSymlinks, "follow-symlinks", false, "Follow symbolic links to directories (symlink loops are detected and skipped)")
+	cmd.Flags().StringArrayVar(&forcedFiles, "file", nil, "Always include this file (relative to the target directory), bypassing filters; repeatable")
+	cmd.Flags().IntVar(&maxDepth, "max-depth", -1, "Descend at most this many directory levels below the target (0 = only top-level files, -1 = unlimited)")
+	cmd.Flags().Var(&fileOrder, "context-order", "Order of the gathered files: "+strings.Join(contextOrders, ", ")+" (mtime puts the most recently edited last)")
+	cmd.Flags().BoolVar(&inclLockfiles, "include-lockfiles", false, "Include dependency lockfiles (go.sum, package-lock.json, yarn.lock, ...), which are skipped by default")
+	cmd.Flags().BoolVar(&excludeGenerated, "exclude-generated", false, "Skip generated files: names matching "+strings.Join(generatedPatterns, ", ")+", or a first line with a \"generated ... DO NOT EDIT\" marker")
+	cmd.Flags().BoolVar(&includeEmpty, "include-empty", false, "Include zero-byte files, which code and gemini skip by default")
+	cmd.Flags().BoolVar(&keepCRLF, "keep-crlf", false, "Keep CRLF line endings in file contents instead of converting them to LF (show always prints files as they are)")
+	cmd.Flags().IntVar(&maxFileLines, "max-file-lines", 0, "Truncate each file to its first N lines, marking the cut with '... [truncated] ...' (0 = no limit)")
+	cmd.Flags().BoolVar(&relatedTests, "related-tests", false, "Only include test files (foo_test.go, test_foo.py, foo.spec.ts, ...) whose subject file is a --file or has uncommitted git changes")
+	cmd.Flags().DurationVar(&staleAge, "context-max-age-warning", 0, "Warn about files modified more than this long before the newest file, e.g. 720h (0 = off)")
+	cmd.Flags().StringArrayVar(&contextGlobs, "context-glob", nil, "Only collect files whose path relative to the target matches this glob (e.g. '**/handlers/*.go'), instead of the usual file filters; repeatable")
+	cmd.Flags().StringArrayVar(&excludeGlobs, "exclude-glob", nil, "Leave out files whose path relative to the target matches this glob (e.g. '**/*_gen.go'); repeatable")
+	cmd.Flags().StringVar(&allowlistFile, "allowlist-file", "", "Read --context-glob patterns from this file, one per line (default: vibe.allow in the target directory, if present)")
+	cmd.Flags().StringVar(&denylistFile, "denylist-file", "", "Read --exclude-glob patterns from this file, one per line (default: vibe.deny in the target directory, if present)")
+	cmd.Flags().IntVar(&maxOpenFiles, "max-open-files", 64, "Most files kept open at once while gathering, for systems with a low open-file limit (0 = no limit)")
+	cmd.Flags().Var(&minFileSize, "min-file-size", "Skip files smaller than this size, e.g. 64B or 1KB (0 includes everything)")
+}
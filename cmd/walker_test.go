@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"testing"
+)
+
+// writeTree creates files (relative path -> content) under a new temporary
+// directory and returns it.
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// gatheredNames returns the sorted base names of files.
+func gatheredNames(files []gatheredFile) []string {
+	var names []string
+	for _, f := range files {
+		names = append(names, filepath.Base(f.path))
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestGatherFilesLockfiles(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"main.go":           "package main\n",
+		"package-lock.json": `{"lockfileVersion": 3}`,
+		"Package-Lock.json": `{"lockfileVersion": 2}`,
+		"yarn.lock":         "# yarn lockfile v1\n",
+		"go.sum":            "example.com/mod v1.0.0 h1:abc=\n",
+		"Cargo.LOCK":        "version = 3\n",
+	})
+	t.Cleanup(func() { inclLockfiles = false })
+
+	tests := []struct {
+		name             string
+		includeLockfiles bool
+		want             []string
+	}{
+		{"skipped by default", false, []string{"main.go"}},
+		{"kept with --include-lockfiles", true, []string{"Cargo.LOCK", "Package-Lock.json", "go.sum", "main.go", "package-lock.json", "yarn.lock"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inclLockfiles = tt.includeLockfiles
+			files, _, err := gatherFiles(codeWalkOptions(root))
+			if err != nil {
+				t.Fatalf("gatherFiles: %v", err)
+			}
+			if got := gatheredNames(files); !slices.Equal(got, tt.want) {
+				t.Errorf("gathered %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGatherFilesShebangScripts(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"main.go": "package main\n",
+		"deploy":  "#!/bin/bash\necho deploying\n",
+		"manage":  "#!/usr/bin/env python3\nprint('managing')\n",
+		"serve":   "#!/usr/bin/env -S node --enable-source-maps\nconsole.log('serving')\n",
+		"NOTES":   "no shebang here\n",
+		"build":   "#!/usr/bin/awk -f\n{ print }\n",
+	})
+	files, _, err := gatherFiles(codeWalkOptions(root))
+	if err != nil {
+		t.Fatalf("gatherFiles: %v", err)
+	}
+	want := []string{"deploy", "main.go", "manage", "serve"}
+	if got := gatheredNames(files); !slices.Equal(got, want) {
+		t.Errorf("gathered %v, want %v", got, want)
+	}
+}
+
+func TestGatherFilesLineEndings(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"main.go": "package main\r\n\r\nfunc main() {}\r\n",
+	})
+	t.Cleanup(func() { keepCRLF = false })
+
+	tests := []struct {
+		name     string
+		keepCRLF bool
+		want     string
+	}{
+		{"converted to LF", false, "package main\n\nfunc main() {}\n"},
+		{"kept with --keep-crlf", true, "package main\r\n\r\nfunc main() {}\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keepCRLF = tt.keepCRLF
+			files, _, err := gatherFiles(codeWalkOptions(root))
+			if err != nil {
+				t.Fatalf("gatherFiles: %v", err)
+			}
+			if len(files) != 1 {
+				t.Fatalf("gathered %v, want only main.go", gatheredNames(files))
+			}
+			if got := string(files[0].content); got != tt.want {
+				t.Errorf("content = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/cobra"
+)
+
+// --- Variables for watch flags ---
+var (
+	watchMode     bool          // Flag to re-run code whenever a context file changes
+	watchDebounce time.Duration // Flag for how long changes must settle before re-running
+	watchClear    bool          // Flag to clear the screen before each re-run
+)
+
+// watchCode runs the code request once, then again each time a file that would
+// be part of its context changes, until interrupted.
+func watchCode(cmd *cobra.Command, args []string) error {
+	if applyChanges {
+		return fmt.Errorf("--watch can't be combined with --apply, since applied files would trigger another run")
+	}
+	if fromClipboard {
+		return fmt.Errorf("--watch can't be combined with --from-clipboard, since there are no files to watch")
+	}
+	targetDir := "."
+	if len(args) == 2 {
+		targetDir = args[1]
+	}
+	root, err := resolveTargetDir(targetDir)
+	if err != nil {
+		return err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to start file watcher: %w", err)
+	}
+	defer watcher.Close()
+	if err := addWatchDirs(watcher, root, root); err != nil {
+		return err
+	}
+
+	run := func() {
+		if watchClear {
+			fmt.Print("\033[H\033[2J") // Move the cursor home and clear the screen
+		}
+		if err := runCode(cmd, args); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+		logf("\nWatching %s for changes (Ctrl+C to stop)...\n", root)
+	}
+	run()
+
+	var debounce <-chan time.Time
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if !watchSkipsDir(root, event.Name) {
+						if err := addWatchDirs(watcher, root, event.Name); err != nil {
+							warnf("watch", "%v\n", err)
+						}
+					}
+					continue
+				}
+			}
+			if event.Has(fsnotify.Chmod) || !watchTriggers(root, event.Name) {
+				continue
+			}
+			verbosef("Change detected: %s\n", event)
+			debounce = time.After(watchDebounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			warnf("watch", "File watcher error: %v\n", err)
+		case <-debounce:
+			debounce = nil
+			run()
+		}
+	}
+}
+
+// addWatchDirs watches dir and every directory below it that code doesn't skip.
+func addWatchDirs(watcher *fsnotify.Watcher, root, dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if path != root && watchSkipsDir(root, path) {
+			return filepath.SkipDir
+		}
+		if err := watcher.Add(path); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", path, err)
+		}
+		return nil
+	})
+}
+
+// watchSkipsDir reports whether dir, or any directory between it and root,
+// is excluded from code's context.
+func watchSkipsDir(root, dir string) bool {
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == "." {
+		return false
+	}
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		if codeSkipDir(part) {
+			return true
+		}
+	}
+	return false
+}
+
+// watchTriggers reports whether a change to path should re-run the request:
+// it must pass code's filters, or be one of the --file paths.
+func watchTriggers(root, path string) bool {
+	for _, f := range forcedFiles {
+		if !filepath.IsAbs(f) {
+			f = filepath.Join(root, f)
+		}
+		if filepath.Clean(f) == path {
+			return true
+		}
+	}
+	return !watchSkipsDir(root, filepath.Dir(path)) && codeIncludeFile(filepath.Base(path))
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import "bytes"
+
+// --- Variables for context whitespace flags ---
+var (
+	stripBlankLines    bool // Flag to drop blank lines from the context
+	collapseWhitespace bool // Flag to squeeze runs of blank lines into one
+)
+
+// normalizeWhitespace applies --strip-blank-lines or --collapse-whitespace to
+// each file and reports the bytes saved. It works purely on whole lines that
+// are empty or whitespace-only, so it never edits a line of code; the only
+// change a language could notice is in multi-line strings containing blank lines.
+func normalizeWhitespace(files []gatheredFile) []gatheredFile {
+	before, after := 0, 0
+	for i, f := range files {
+		before += len(f.content)
+		files[i].content = squeezeBlankLines(f.content, stripBlankLines)
+		after += len(files[i].content)
+	}
+	if saved := before - after; saved > 0 {
+		logf("Whitespace normalization removed %d of %d bytes (~%d tokens).\n", saved, before, estimateTokens(saved))
+	}
+	return files
+}
+
+// squeezeBlankLines removes whitespace-only lines from content, or with strip
+// unset keeps the first of each run of them (as an empty line).
+func squeezeBlankLines(content []byte, strip bool) []byte {
+	var out bytes.Buffer
+	out.Grow(len(content))
+	prevBlank := false
+	for _, line := range bytes.SplitAfter(content, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		blank := len(bytes.TrimSpace(line)) == 0
+		if blank && (strip || prevBlank) {
+			continue
+		}
+		if blank {
+			line = []byte("\n")
+		}
+		out.Write(line)
+		prevBlank = blank
+	}
+	return out.Bytes()
+}
@@ -0,0 +1,17 @@
+// Package version holds build metadata for the vibe binary.
+// The values are overridden at link time via -ldflags (see the Makefile).
+package version
+
+var (
+	// Version is the release version, e.g. from `git describe`.
+	Version = "0.1.1"
+	// GitCommit is the short hash of the commit the binary was built from.
+	GitCommit = "unknown"
+	// GitCommitDate is the commit date of GitCommit.
+	GitCommitDate = "unknown"
+)
+
+// UserAgent returns the identifier vibe sends to upstream APIs.
+func UserAgent() string {
+	return "vibe-code/" + Version
+}